@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"ShareGenerationClient/config"
+	"ShareGenerationClient/internal"
+	"fmt"
+	"github.com/spf13/cobra"
+)
+
+// broadcastCmd represents the broadcast command
+var broadcastCmd = &cobra.Command{
+	Use:   "broadcast",
+	Short: "Broadcast a tx signed offline",
+	Long: `Broadcast a tx signed with: generate --offline, after checking the account sequence, pub keys
+& validator set did not change since its snapshot was exported`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.ReadConfigFromFile()
+		if err != nil {
+			fmt.Printf("Error loading config from file: %s\n", err.Error())
+			return
+		}
+
+		txFile, _ := cmd.Flags().GetString("tx")
+
+		signed, err := internal.ReadSignedTxFile(txFile)
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+
+		ctx := cmd.Context()
+
+		masterClient, err := internal.NewShareGeneratorClient(ctx, cfg)
+		if err != nil {
+			fmt.Printf("Error creating client: %s\n", err.Error())
+			return
+		}
+		defer masterClient.Close()
+
+		txResp, err := masterClient.BroadcastOffline(ctx, cfg.FairyRingNode.ChainID, signed)
+		if err != nil {
+			fmt.Printf("Error broadcasting tx: %s\n", err.Error())
+			return
+		}
+		if txResp.TxResponse.Code != 0 {
+			fmt.Printf("Tx %s failed: %s\n", txResp.TxResponse.TxHash, txResp.TxResponse.RawLog)
+			return
+		}
+		fmt.Printf("Tx %s included at height %d, Pub Key: %s\n", txResp.TxResponse.TxHash, txResp.TxResponse.Height, signed.PubKey)
+	},
+}
+
+func init() {
+	broadcastCmd.Flags().String("tx", "signed_tx.json", "Signed tx file written by: generate --offline")
+	rootCmd.AddCommand(broadcastCmd)
+}
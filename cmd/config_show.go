@@ -24,9 +24,36 @@ var configShowCmd = &cobra.Command{
 FairyRing Node Endpoint: %s
 Chain ID: %s
 Chain Denom: %s
-CheckInterval: %d
+CheckInterval: %s
 MetricsPort: %d
-`, cfg.GetGRPCEndpoint(), cfg.GetFairyRingNodeURI(), cfg.FairyRingNode.ChainID, cfg.FairyRingNode.Denom, cfg.CheckInterval,cfg.MetricsPort)
+Verification Quorum: %d / %d
+Pin Policy: %s
+Sign Mode: %s
+Gas Price: %s
+Max Fee: %s
+Fee Granter: %s
+Auto Fee: %t | Bump Factor: %s | Max Bumps: %d
+Tx Timeout: %d blocks | Max Rebroadcasts: %d
+Retry Budget: %d | Backoff: %s - %s
+Websocket Stall Timeout: %s | Poll Interval: %s
+Shutdown Timeout: %s
+Generate Before Expiry: %d blocks / %s | Alert Before Expiry: %d blocks
+Auto Override: %t | Min Overlap: %g
+Participation Monitor: %t | Window: %d blocks
+Encryption Canary: %t | Interval: %s | Target: %d blocks | Verify Within: %d blocks
+Decryption Key Monitor: %t | Max Lag: %d blocks | Alert After: %d missing
+`, cfg.GetGRPCEndpoint(), cfg.GetFairyRingNodeURI(), cfg.FairyRingNode.ChainID, cfg.FairyRingNode.Denom, cfg.CheckInterval,cfg.MetricsPort, cfg.GetVerificationQuorum(), len(cfg.VerificationNodes)+1, cfg.PinPolicy, cfg.SignMode, cfg.Fee.GasPrice, cfg.Fee.MaxFee, cfg.Fee.Granter, cfg.Fee.Auto, cfg.Fee.BumpFactor, cfg.Fee.MaxBumps, cfg.Tx.TimeoutBlocks, cfg.Tx.MaxRebroadcasts, cfg.Retry.Budget, cfg.Retry.InitialBackoff, cfg.Retry.MaxBackoff, cfg.Websocket.StallTimeout, cfg.Websocket.PollInterval, cfg.ShutdownTimeout, cfg.Schedule.GenerateBeforeExpiryBlocks, cfg.Schedule.GenerateBeforeExpiry, cfg.Schedule.AlertBeforeExpiryBlocks, cfg.AutoOverride.Enabled, cfg.AutoOverride.MinOverlap, cfg.Participation.Enabled, cfg.Participation.Window, cfg.Canary.Enabled, cfg.Canary.Interval, cfg.Canary.TargetBlocks, cfg.Canary.VerifyBlocks, cfg.KeyMonitor.Enabled, cfg.KeyMonitor.MaxLagBlocks, cfg.KeyMonitor.AlertAfterMissing)
+
+		if cfg.LightClient.Enabled {
+			fmt.Printf("Light Client Trusted Height: %d | Hash: %s | Trusting Period: %s\n", cfg.LightClient.TrustedHeight, cfg.LightClient.TrustedHash, cfg.LightClient.TrustingPeriod)
+			for _, w := range cfg.LightClient.Witnesses {
+				fmt.Printf("Light Client Witness: %s\n", w)
+			}
+		}
+
+		for _, n := range cfg.VerificationNodes {
+			fmt.Printf("Verification Node GRPC Endpoint: %s\n", n.GetGRPCEndpoint())
+		}
 
 		if showPKey {
 			fmt.Printf("Private Key: %s\n", cfg.PrivateKey)
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"ShareGenerationClient/config"
+	"ShareGenerationClient/pkg/cosmosClient"
 	"fmt"
 	"github.com/spf13/cobra"
 )
@@ -24,9 +25,45 @@ var configUpdateCmd = &cobra.Command{
 		chainProtocol, _ := cmd.Flags().GetString("protocol")
 		chainGrpcPort, _ := cmd.Flags().GetUint64("grpc-port")
 		chainPort, _ := cmd.Flags().GetUint64("port")
-		checkInterval, _ := cmd.Flags().GetUint64("check-interval")
+		checkInterval, _ := cmd.Flags().GetString("check-interval")
 		privateKey, _ := cmd.Flags().GetString("private-key")
 		metricsPort, _ := cmd.Flags().GetUint64("metrics-port")
+		verificationQuorum, _ := cmd.Flags().GetUint64("verification-quorum")
+		lightClientEnabled, _ := cmd.Flags().GetBool("light-client")
+		trustedHeight, _ := cmd.Flags().GetInt64("trusted-height")
+		trustedHash, _ := cmd.Flags().GetString("trusted-hash")
+		witnesses, _ := cmd.Flags().GetStringSlice("light-client-witnesses")
+		verificationNodes, _ := cmd.Flags().GetStringSlice("verification-nodes")
+		pinPolicy, _ := cmd.Flags().GetString("pin-policy")
+		signMode, _ := cmd.Flags().GetString("sign-mode")
+		gasPrice, _ := cmd.Flags().GetString("gas-price")
+		maxFee, _ := cmd.Flags().GetString("max-fee")
+		feeGranter, _ := cmd.Flags().GetString("fee-granter")
+		autoFee, _ := cmd.Flags().GetBool("auto-fee")
+		feeBumpFactor, _ := cmd.Flags().GetString("fee-bump-factor")
+		feeMaxBumps, _ := cmd.Flags().GetUint64("fee-max-bumps")
+		txTimeoutBlocks, _ := cmd.Flags().GetUint64("tx-timeout-blocks")
+		txMaxRebroadcasts, _ := cmd.Flags().GetUint64("tx-max-rebroadcasts")
+		retryBudget, _ := cmd.Flags().GetUint64("retry-budget")
+		retryInitialBackoff, _ := cmd.Flags().GetDuration("retry-initial-backoff")
+		retryMaxBackoff, _ := cmd.Flags().GetDuration("retry-max-backoff")
+		websocketStallTimeout, _ := cmd.Flags().GetDuration("websocket-stall-timeout")
+		websocketPollInterval, _ := cmd.Flags().GetDuration("websocket-poll-interval")
+		shutdownTimeout, _ := cmd.Flags().GetDuration("shutdown-timeout")
+		generateBeforeExpiryBlocks, _ := cmd.Flags().GetUint64("generate-before-expiry-blocks")
+		generateBeforeExpiry, _ := cmd.Flags().GetDuration("generate-before-expiry")
+		alertBeforeExpiryBlocks, _ := cmd.Flags().GetUint64("alert-before-expiry-blocks")
+		autoOverride, _ := cmd.Flags().GetBool("auto-override")
+		autoOverrideMinOverlap, _ := cmd.Flags().GetFloat64("auto-override-min-overlap")
+		participation, _ := cmd.Flags().GetBool("participation")
+		participationWindow, _ := cmd.Flags().GetUint64("participation-window")
+		canary, _ := cmd.Flags().GetBool("canary")
+		canaryInterval, _ := cmd.Flags().GetDuration("canary-interval")
+		canaryTargetBlocks, _ := cmd.Flags().GetUint64("canary-target-blocks")
+		canaryVerifyBlocks, _ := cmd.Flags().GetUint64("canary-verify-blocks")
+		keyMonitor, _ := cmd.Flags().GetBool("key-monitor")
+		keyMonitorMaxLagBlocks, _ := cmd.Flags().GetUint64("key-monitor-max-lag-blocks")
+		keyMonitorAlertAfterMissing, _ := cmd.Flags().GetUint64("key-monitor-alert-after-missing")
 
 		cfg.FairyRingNode = config.Node{
 			Protocol: chainProtocol,
@@ -37,9 +74,120 @@ var configUpdateCmd = &cobra.Command{
 			ChainID:  chainID,
 		}
 
+		if _, _, err = config.ParseCheckInterval(checkInterval); err != nil {
+			fmt.Printf("Invalid check interval: %s\n", err.Error())
+			return
+		}
 		cfg.CheckInterval = checkInterval
 		cfg.PrivateKey = privateKey
 		cfg.MetricsPort = metricsPort
+		cfg.VerificationQuorum = verificationQuorum
+
+		nodes := make([]config.Node, 0, len(verificationNodes))
+		for _, endpoint := range verificationNodes {
+			node, err := config.ParseVerificationNode(endpoint)
+			if err != nil {
+				fmt.Printf("Invalid verification nodes: %s\n", err.Error())
+				return
+			}
+			nodes = append(nodes, node)
+		}
+		cfg.VerificationNodes = nodes
+
+		distinctWitnesses := 0
+		for _, w := range witnesses {
+			if w != cfg.GetFairyRingNodeURI() {
+				distinctWitnesses++
+			}
+		}
+		if lightClientEnabled && distinctWitnesses == 0 {
+			fmt.Println("Invalid light client config: at least one witness distinct from the FairyRing node is required")
+			return
+		}
+		cfg.LightClient.Enabled = lightClientEnabled
+		cfg.LightClient.TrustedHeight = trustedHeight
+		cfg.LightClient.TrustedHash = trustedHash
+		cfg.LightClient.Witnesses = witnesses
+
+		if len(pinPolicy) == 0 {
+			pinPolicy = config.PinPolicyManual
+		}
+		if pinPolicy != config.PinPolicyManual && pinPolicy != config.PinPolicyAccept {
+			fmt.Printf("Invalid pin policy: '%s', expected '%s' or '%s'\n", pinPolicy, config.PinPolicyManual, config.PinPolicyAccept)
+			return
+		}
+		cfg.PinPolicy = pinPolicy
+
+		if _, err = cosmosClient.ParseSignMode(signMode); err != nil {
+			fmt.Printf("Invalid sign mode: %s\n", err.Error())
+			return
+		}
+		cfg.SignMode = signMode
+
+		if _, err = cosmosClient.ParseFeeOptions(gasPrice, maxFee, feeGranter, chainDenom); err != nil {
+			fmt.Printf("Invalid fee config: %s\n", err.Error())
+			return
+		}
+		if _, err = cosmosClient.ParseBumpFactor(feeBumpFactor); err != nil {
+			fmt.Printf("Invalid fee config: %s\n", err.Error())
+			return
+		}
+		cfg.Fee = config.Fee{
+			GasPrice:   gasPrice,
+			MaxFee:     maxFee,
+			Granter:    feeGranter,
+			Auto:       autoFee,
+			BumpFactor: feeBumpFactor,
+			MaxBumps:   feeMaxBumps,
+		}
+		cfg.Tx = config.Tx{
+			TimeoutBlocks:   txTimeoutBlocks,
+			MaxRebroadcasts: txMaxRebroadcasts,
+		}
+
+		if retryInitialBackoff > retryMaxBackoff {
+			fmt.Printf("Invalid retry config: initial backoff %s is greater than max backoff %s\n", retryInitialBackoff, retryMaxBackoff)
+			return
+		}
+		cfg.Retry = config.Retry{
+			InitialBackoff: retryInitialBackoff,
+			MaxBackoff:     retryMaxBackoff,
+			Budget:         retryBudget,
+		}
+		cfg.Websocket = config.Websocket{
+			StallTimeout: websocketStallTimeout,
+			PollInterval: websocketPollInterval,
+		}
+		cfg.ShutdownTimeout = shutdownTimeout
+		cfg.Schedule = config.Schedule{
+			GenerateBeforeExpiryBlocks: generateBeforeExpiryBlocks,
+			GenerateBeforeExpiry:       generateBeforeExpiry,
+			AlertBeforeExpiryBlocks:    alertBeforeExpiryBlocks,
+		}
+
+		if autoOverrideMinOverlap < 0 || autoOverrideMinOverlap > 1 {
+			fmt.Printf("Invalid auto override min overlap: %g, expected a fraction between 0 and 1\n", autoOverrideMinOverlap)
+			return
+		}
+		cfg.AutoOverride = config.AutoOverride{
+			Enabled:    autoOverride,
+			MinOverlap: autoOverrideMinOverlap,
+		}
+		cfg.Participation = config.Participation{
+			Enabled: participation,
+			Window:  participationWindow,
+		}
+		cfg.Canary = config.Canary{
+			Enabled:      canary,
+			Interval:     canaryInterval,
+			TargetBlocks: canaryTargetBlocks,
+			VerifyBlocks: canaryVerifyBlocks,
+		}
+		cfg.KeyMonitor = config.KeyMonitor{
+			Enabled:           keyMonitor,
+			MaxLagBlocks:      keyMonitorMaxLagBlocks,
+			AlertAfterMissing: keyMonitorAlertAfterMissing,
+		}
 
 		if err = cfg.SaveConfig(); err != nil {
 			fmt.Printf("Error saving updated config to system: %s\n", err.Error())
@@ -63,7 +211,47 @@ func init() {
 	configUpdateCmd.Flags().String("ip", cfg.FairyRingNode.IP, "Update config node ip address")
 	configUpdateCmd.Flags().Uint64("port", cfg.FairyRingNode.Port, "Update config node port")
 	configUpdateCmd.Flags().String("protocol", cfg.FairyRingNode.Protocol, "Update config node protocol")
-	configUpdateCmd.Flags().Uint64("check-interval", cfg.CheckInterval, "How often the client check for pub key status in blocks")
+	configUpdateCmd.Flags().String("check-interval", cfg.CheckInterval, "How often the client check for pub key status, in blocks like '50' or as a duration like '5m'")
 	configUpdateCmd.Flags().String("private-key", cfg.PrivateKey, "Private key for the trusted address")
 	configUpdateCmd.Flags().Uint64("metrics-port", cfg.MetricsPort, "Update config metrics port")
+	configUpdateCmd.Flags().Bool("light-client", cfg.LightClient.Enabled, "Verify validators info with light client proofs, omitted validators are not detected without verification nodes")
+	configUpdateCmd.Flags().Int64("trusted-height", cfg.LightClient.TrustedHeight, "Update light client trusted height")
+	configUpdateCmd.Flags().String("trusted-hash", cfg.LightClient.TrustedHash, "Update light client trusted header hash in hex")
+	configUpdateCmd.Flags().StringSlice("light-client-witnesses", cfg.LightClient.Witnesses, "Light client witness RPC endpoints cross-checking headers, e.g. 'http://10.0.0.4:26657'")
+	configUpdateCmd.Flags().String("gas-price", cfg.Fee.GasPrice, "Update config gas price, e.g. '0.025ufair', chain denom is used if not specified")
+	configUpdateCmd.Flags().String("max-fee", cfg.Fee.MaxFee, "Update config max fee per tx, e.g. '100000ufair', empty for no limit")
+	configUpdateCmd.Flags().String("fee-granter", cfg.Fee.Granter, "Update config fee granter address")
+	configUpdateCmd.Flags().Bool("auto-fee", cfg.Fee.Auto, "Discover gas price from the node & fee market module, bump it on insufficient fee")
+	configUpdateCmd.Flags().String("fee-bump-factor", cfg.Fee.BumpFactor, "Update config gas price multiplier on insufficient fee")
+	configUpdateCmd.Flags().Uint64("fee-max-bumps", cfg.Fee.MaxBumps, "Update config max number of gas price bumps per tx")
+	configUpdateCmd.Flags().Uint64("tx-timeout-blocks", cfg.Tx.TimeoutBlocks, "Number of blocks a submitted tx is valid for before being rebroadcast, 0 for no timeout")
+	configUpdateCmd.Flags().Uint64("tx-max-rebroadcasts", cfg.Tx.MaxRebroadcasts, "Update config max number of times an expired tx is rebroadcast with a higher fee")
+	configUpdateCmd.Flags().Uint64("retry-budget", cfg.Retry.Budget, "Max number of retries on transient errors per pub key check")
+	configUpdateCmd.Flags().Duration("retry-initial-backoff", cfg.Retry.InitialBackoff, "Update config delay before the first retry, doubled on every retry")
+	configUpdateCmd.Flags().Duration("retry-max-backoff", cfg.Retry.MaxBackoff, "Update config max delay between two retries")
+	configUpdateCmd.Flags().Duration("websocket-stall-timeout", cfg.Websocket.StallTimeout, "Resubscribe to block headers when none is received for this long")
+	configUpdateCmd.Flags().Duration("websocket-poll-interval", cfg.Websocket.PollInterval, "How often the latest height is polled while the websocket subscription is down")
+	configUpdateCmd.Flags().Duration("shutdown-timeout", cfg.ShutdownTimeout, "How long to wait for an in flight tx on shutdown")
+	configUpdateCmd.Flags().Uint64("generate-before-expiry-blocks", cfg.Schedule.GenerateBeforeExpiryBlocks, "Generate the queued pub key when the active one expires within this many blocks, 0 with no duration to generate it as soon as there is none")
+	configUpdateCmd.Flags().Duration("generate-before-expiry", cfg.Schedule.GenerateBeforeExpiry, "Generate the queued pub key when the active one expires within this estimated time, 0 to disable")
+	configUpdateCmd.Flags().Uint64("alert-before-expiry-blocks", cfg.Schedule.AlertBeforeExpiryBlocks, "Alert when the active pub key expires within this many blocks without any queued pub key")
+	configUpdateCmd.Flags().Bool("auto-override", cfg.AutoOverride.Enabled, "Override the active pub key when the validator set drifts from its key holders")
+	configUpdateCmd.Flags().Float64("auto-override-min-overlap", cfg.AutoOverride.MinOverlap, "Override the active pub key when the overlap of its key holders & the validator set drops below this fraction")
+	configUpdateCmd.Flags().Bool("participation", cfg.Participation.Enabled, "Monitor the keyshares submitted by the active pub key holders every block")
+	configUpdateCmd.Flags().Uint64("participation-window", cfg.Participation.Window, "Number of blocks the validators participation rate is computed over")
+	configUpdateCmd.Flags().Bool("canary", cfg.Canary.Enabled, "Periodically submit an encrypted tx to the pep module and verify it is decrypted & executed")
+	configUpdateCmd.Flags().Duration("canary-interval", cfg.Canary.Interval, "How often the encryption canary is submitted")
+	configUpdateCmd.Flags().Uint64("canary-target-blocks", cfg.Canary.TargetBlocks, "Number of blocks after submission the canary is encrypted for")
+	configUpdateCmd.Flags().Uint64("canary-verify-blocks", cfg.Canary.VerifyBlocks, "Number of blocks after the target height the canary has to be executed within")
+	configUpdateCmd.Flags().Bool("key-monitor", cfg.KeyMonitor.Enabled, "Monitor the decryption key aggregated for every height")
+	configUpdateCmd.Flags().Uint64("key-monitor-max-lag-blocks", cfg.KeyMonitor.MaxLagBlocks, "Number of blocks after its height a decryption key is considered missing")
+	configUpdateCmd.Flags().Uint64("key-monitor-alert-after-missing", cfg.KeyMonitor.AlertAfterMissing, "Alert when this many consecutive decryption keys are missing")
+	configUpdateCmd.Flags().String("sign-mode", cfg.SignMode, "Update config sign mode: 'direct' or 'amino-json'")
+	configUpdateCmd.Flags().String("pin-policy", cfg.PinPolicy, "What to do when a validator encryption key changes: 'manual' or 'accept'")
+	verificationNodes := make([]string, 0, len(cfg.VerificationNodes))
+	for _, n := range cfg.VerificationNodes {
+		verificationNodes = append(verificationNodes, n.GetGRPCEndpoint())
+	}
+	configUpdateCmd.Flags().StringSlice("verification-nodes", verificationNodes, "Additional nodes gRPC endpoints the validator set is verified with, e.g. '10.0.0.2:9090'")
+	configUpdateCmd.Flags().Uint64("verification-quorum", cfg.VerificationQuorum, "Number of nodes that must return the same validator set, 0 for all nodes")
 }
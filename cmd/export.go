@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"ShareGenerationClient/config"
+	"ShareGenerationClient/internal"
+	"fmt"
+	"github.com/spf13/cobra"
+)
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export chain state for the offline workflow",
+	Long:  `Export chain state for generating & signing the pub key on an air-gapped machine`,
+}
+
+// exportValidatorSetCmd represents the export validator-set command
+var exportValidatorSetCmd = &cobra.Command{
+	Use:   "validator-set",
+	Short: "Export the verified validator set & account state to a snapshot file",
+	Long: `Export the verified validator set, their encryption keys, the account number, sequence & chain id to a snapshot file,
+to generate & sign the pub key offline with: generate --offline --input <snapshot>`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.ReadConfigFromFile()
+		if err != nil {
+			fmt.Printf("Error loading config from file: %s\n", err.Error())
+			return
+		}
+
+		out, _ := cmd.Flags().GetString("out")
+		msgType, _ := cmd.Flags().GetString("msg")
+		gasLimit, _ := cmd.Flags().GetUint64("gas-limit")
+		timeoutBlocks, _ := cmd.Flags().GetUint64("timeout-blocks")
+
+		ctx := cmd.Context()
+
+		masterClient, err := internal.NewShareGeneratorClient(ctx, cfg)
+		if err != nil {
+			fmt.Printf("Error creating client: %s\n", err.Error())
+			return
+		}
+		defer masterClient.Close()
+
+		snapshot, err := masterClient.ExportValidatorSet(ctx, cfg.FairyRingNode.ChainID, msgType, gasLimit, timeoutBlocks)
+		if err != nil {
+			fmt.Printf("Error exporting validator set: %s\n", err.Error())
+			return
+		}
+		if err = snapshot.Write(out); err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+
+		fmt.Printf(
+			"Validator set of %d validators at height %d exported to %s | Sequence: %d | Gas: %d | Fee: %s\n",
+			len(snapshot.Validators), snapshot.Height, out, snapshot.SignParams.Sequence, snapshot.SignParams.GasLimit, snapshot.SignParams.Fee,
+		)
+	},
+}
+
+func init() {
+	exportValidatorSetCmd.Flags().String("out", "snapshot.json", "Snapshot file to write")
+	exportValidatorSetCmd.Flags().String("msg", internal.OfflineMsgCreate, "Msg to sign offline: create or override")
+	exportValidatorSetCmd.Flags().Uint64("gas-limit", 0, "Gas limit of the tx, simulated if 0")
+	exportValidatorSetCmd.Flags().Uint64("timeout-blocks", 0, "Number of blocks the signed tx is valid for, 0 for no timeout")
+	exportCmd.AddCommand(exportValidatorSetCmd)
+	rootCmd.AddCommand(exportCmd)
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"ShareGenerationClient/config"
+	"ShareGenerationClient/internal"
+	"fmt"
+	"github.com/spf13/cobra"
+)
+
+// generateCmd represents the generate command
+var generateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate & sign the pub key offline",
+	Long: `Generate the pub key for the validators of a snapshot exported with: export validator-set,
+and sign its tx without any node connection. Broadcast the signed tx file with: broadcast --tx <file>`,
+	Run: func(cmd *cobra.Command, args []string) {
+		offline, _ := cmd.Flags().GetBool("offline")
+		if !offline {
+			fmt.Println("Only offline generation is supported, the pub key is generated online by start & override")
+			return
+		}
+
+		cfg, err := config.ReadConfigFromFile()
+		if err != nil {
+			fmt.Printf("Error loading config from file: %s\n", err.Error())
+			return
+		}
+
+		input, _ := cmd.Flags().GetString("input")
+		out, _ := cmd.Flags().GetString("out")
+
+		snapshot, err := internal.ReadValidatorSetSnapshot(input)
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+
+		signed, err := internal.GenerateOffline(cmd.Context(), cfg, snapshot)
+		if err != nil {
+			fmt.Printf("Error generating pub key: %s\n", err.Error())
+			return
+		}
+		if err = signed.Write(out); err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+
+		fmt.Printf("Pub Key: %s\n", signed.PubKey)
+		fmt.Printf("Validators: %d | Threshold: %d\n", len(snapshot.Validators), internal.Threshold(len(snapshot.Validators)))
+		fmt.Printf("Signed tx %s written to %s\n", signed.Hash, out)
+	},
+}
+
+func init() {
+	generateCmd.Flags().Bool("offline", false, "Generate & sign without any node connection")
+	generateCmd.Flags().String("input", "snapshot.json", "Snapshot file exported with: export validator-set")
+	generateCmd.Flags().String("out", "signed_tx.json", "Signed tx file to write")
+	rootCmd.AddCommand(generateCmd)
+}
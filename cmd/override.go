@@ -4,22 +4,28 @@ import (
 	"ShareGenerationClient/config"
 	"ShareGenerationClient/internal"
 	"ShareGenerationClient/pkg/cosmosClient"
-	"encoding/hex"
+	"context"
 	"fmt"
-	"github.com/Fairblock/fairyring/x/keyshare/types"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"log"
-	"math/big"
-	"slices"
-	"strconv"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 )
 
 // overrideCmd represents the start command
 var overrideCmd = &cobra.Command{
 	Use:   "override",
 	Short: "Manually override current active public key",
-	Long:  `Manually override current active public key`,
+	Long: `Manually override current active public key
+
+The validators to generate the new pub key for are selected interactively by default,
+or with --include / --exclude taking indices, addresses or monikers.
+The selection can be written to a plan with --plan-out for review, and executed later with --plan-in.
+With --at-height, the override waits for the given block height and selects the validators again at that height`,
 	Run: func(cmd *cobra.Command, args []string) {
 		cfg, err := config.ReadConfigFromFile()
 		if err != nil {
@@ -27,19 +33,29 @@ var overrideCmd = &cobra.Command{
 			return
 		}
 
-		privateKey := cfg.PrivateKey
-		gRPCEndpoint := cfg.GetGRPCEndpoint()
-
-		cClient, err := cosmosClient.NewCosmosClient(gRPCEndpoint, privateKey, cfg.FairyRingNode.ChainID)
-		if err != nil {
-			log.Fatalf("Couldn't create cosmos client: %s", err.Error())
+		include, _ := cmd.Flags().GetStringSlice("include")
+		exclude, _ := cmd.Flags().GetStringSlice("exclude")
+		planIn, _ := cmd.Flags().GetString("plan-in")
+		planOut, _ := cmd.Flags().GetString("plan-out")
+		skipPrompts, _ := cmd.Flags().GetBool("yes")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		atHeight, _ := cmd.Flags().GetInt64("at-height")
+		diffFormat, _ := cmd.Flags().GetString("diff-format")
+		if diffFormat != internal.DiffFormatTable && diffFormat != internal.DiffFormatJSON {
+			fmt.Printf("Invalid diff format '%s', expected %s or %s\n", diffFormat, internal.DiffFormatTable, internal.DiffFormatJSON)
+			return
 		}
 
-		masterClient := internal.ShareGeneratorClient{
-			CosmosClient: cClient,
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		masterClient, err := internal.NewShareGeneratorClient(ctx, cfg)
+		if err != nil {
+			log.Fatal(err)
 		}
+		defer masterClient.Close()
 
-		pubKeyValidatorsInfo, err := cClient.GetCurrentPubKeyValidatorsInfo()
+		pubKeyValidatorsInfo, activeValidators, err := masterClient.CosmosClient.GetCurrentPubKeyHolders(ctx)
 		if err != nil {
 			log.Fatalf("Couldn't get validators info from current public key: %s", err.Error())
 		}
@@ -60,7 +76,7 @@ var overrideCmd = &cobra.Command{
 
 		fmt.Println("================")
 
-		validatorsInfo, err := cClient.GetAllValidatorsPubInfos()
+		validatorsInfo, err := masterClient.GetVerifiedValidatorsPubInfos(ctx)
 		if err != nil {
 			log.Fatalf("Couldn't get validators info: %s", err.Error())
 		}
@@ -72,93 +88,261 @@ var overrideCmd = &cobra.Command{
 		fmt.Printf("Found total %d validators in key share module\n", len(validatorsInfo))
 
 		for i, v := range validatorsInfo {
-			if v.Description == nil {
-				fmt.Printf("[%d] 'Authorized By %s': %s\n", i, v.AuthorizedBy, v.Address)
-			} else {
-				fmt.Printf("[%d] '%s': %s\n", i, v.Description.Moniker, v.Address)
-			}
+			fmt.Printf("[%d] '%s': %s\n", i, internal.ValidatorLabel(v), v.Address)
 		}
 
 		fmt.Println("================")
 
-		var validatorsIndexesStr string
-		fmt.Print("Enter the index of the validators to be removed, separate with comma (Enter -1 to keep all validators): ")
-		_, err = fmt.Scan(&validatorsIndexesStr)
-
-		var splitIndexes []string
+		var plan *internal.OverridePlan
+		var newValidatorInfo, removedValidatorInfo []cosmosClient.ValidatorPubInfo
+		switch {
+		case len(planIn) > 0:
+			plan, err = internal.ReadOverridePlan(planIn)
+			if err != nil {
+				log.Fatalf("Invalid override plan: %s", err.Error())
+			}
+			newValidatorInfo, err = plan.Resolve(cfg.FairyRingNode.ChainID, validatorsInfo)
+			if err != nil {
+				log.Fatalf("Override plan is outdated: %s", err.Error())
+			}
+			fmt.Printf("Override plan made at height %d on %s\n", plan.Height, plan.CreatedAt.Format(time.RFC3339))
+		case len(include) > 0 || len(exclude) > 0:
+			newValidatorInfo, removedValidatorInfo, err = internal.SelectValidators(validatorsInfo, include, exclude)
+			if err != nil {
+				log.Fatalf("Invalid validators selection: %s", err.Error())
+			}
+		case skipPrompts:
+			fmt.Println("No --include / --exclude selector given with --yes, keeping all validators")
+			newValidatorInfo = validatorsInfo
+		default:
+			var validatorsIndexesStr string
+			fmt.Print("Enter the index of the validators to be removed, separate with comma (Enter -1 to keep all validators): ")
+			if _, err = fmt.Scan(&validatorsIndexesStr); err != nil {
+				log.Fatalf("Unable to read the validators to remove, use --include / --exclude when not run interactively: %s", err.Error())
+			}
 
-		if validatorsIndexesStr == "-1" {
-			splitIndexes = make([]string, 0)
-		} else {
-			splitIndexes = strings.Split(validatorsIndexesStr, ",")
-			if len(splitIndexes) <= 0 || len(splitIndexes) >= len(validatorsInfo) {
-				log.Fatalln("Invalid number of given validators")
+			var splitIndexes []string
+			if validatorsIndexesStr != "-1" {
+				splitIndexes = strings.Split(validatorsIndexesStr, ",")
 			}
-		}
 
-		indexesToRemove := make([]int, 0)
-		for _, v := range splitIndexes {
-			number, err := strconv.Atoi(v)
+			newValidatorInfo, removedValidatorInfo, err = internal.SelectValidators(validatorsInfo, nil, splitIndexes)
 			if err != nil {
-				log.Fatalf("Invalid given index, expected number, got: '%s': %s", v, err.Error())
+				log.Fatalf("Invalid given validators: %s", err.Error())
 			}
-			indexesToRemove = append(indexesToRemove, number)
 		}
 
-		fmt.Println("Validator(s) to be removed:")
-		var newValidatorInfo []cosmosClient.ValidatorPubInfo
-		for i, v := range validatorsInfo {
-			if slices.Contains(indexesToRemove, i) {
-				fmt.Printf("[%d] '%s': %s\n", i, v.Description.Moniker, v.Address)
-			} else {
-				newValidatorInfo = append(newValidatorInfo, v)
+		if len(removedValidatorInfo) > 0 {
+			fmt.Println("Validator(s) to be removed:")
+			for _, v := range removedValidatorInfo {
+				fmt.Printf("'%s': %s\n", internal.ValidatorLabel(v), v.Address)
 			}
 		}
 
-		generatedResult := masterClient.Generate(newValidatorInfo)
-		if generatedResult == nil {
-			log.Fatal("Generate result is empty")
-		}
+		printOverrideDiff(ctx, masterClient, pubKeyValidatorsInfo, activeValidators, newValidatorInfo, diffFormat)
 
-		n := len(generatedResult.EncryptedKeyShares)
+		if len(planOut) > 0 {
+			height, err := masterClient.CosmosClient.GetChainHeight(ctx)
+			if err != nil {
+				log.Fatalf("Couldn't get chain height: %s", err.Error())
+			}
+			plan = internal.NewOverridePlan(cfg.FairyRingNode.ChainID, height, newValidatorInfo, removedValidatorInfo)
+			if err = plan.Write(planOut); err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("Override plan written to %s, execute it once reviewed with: override --plan-in %s\n", planOut, planOut)
+			return
+		}
 
-		encShares := make([]*types.EncryptedKeyshare, n)
+		if atHeight > 0 {
+			// Included validators are pinned, otherwise validators joining until the target height are kept
+			schedule := internal.NewScheduledOverride(cfg.FairyRingNode.ChainID, atHeight, nil, removedValidatorInfo)
+			if len(include) > 0 {
+				schedule = internal.NewScheduledOverride(cfg.FairyRingNode.ChainID, atHeight, newValidatorInfo, nil)
+			}
+			schedule.Plan = plan
 
-		for _, v := range generatedResult.EncryptedKeyShares {
-			indexByte, _ := hex.DecodeString(v.Index.String())
-			indexInt := big.NewInt(0).SetBytes(indexByte).Uint64()
-			encShares[indexInt-1] = &types.EncryptedKeyshare{
-				Data:      v.EncShare,
-				Validator: v.ValidatorAddress,
+			if err = scheduleOverride(ctx, cfg, masterClient, schedule, dryRun || skipPrompts, dryRun, diffFormat); err != nil {
+				log.Fatalf("Scheduled override failed: %s", err.Error())
 			}
+			return
 		}
 
-		txMsg := types.MsgOverrideLatestPubkey{
-			Creator:            masterClient.CosmosClient.GetAddress(),
-			PublicKey:          generatedResult.MasterPublicKey,
-			Commitments:        generatedResult.Commitments,
-			NumberOfValidators: uint64(n),
-			EncryptedKeyshares: encShares,
+		if !dryRun && !skipPrompts && !confirm("Override the active pub key?") {
+			fmt.Println("Override aborted")
+			return
 		}
 
-		err = txMsg.ValidateBasic()
+		if err = submitOverride(ctx, masterClient, newValidatorInfo, dryRun, nil); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+// overrideCancelCmd cancels the override waiting for its target height
+var overrideCancelCmd = &cobra.Command{
+	Use:   "cancel",
+	Short: "Cancel the scheduled override",
+	Long:  `Cancel the override waiting for its target height, the waiting process exits on the next block`,
+	Run: func(cmd *cobra.Command, args []string) {
+		path, err := internal.DefaultScheduledOverridePath()
 		if err != nil {
-			log.Fatalf("Failed to override latest pubkey, validate basic failed: %s", err.Error())
+			log.Fatal(err)
 		}
 
-		txResp, err := masterClient.CosmosClient.BroadcastTx(
-			&txMsg,
-			true,
+		cancelled, err := internal.CancelScheduledOverride(path)
+		if err != nil {
+			log.Fatalf("Couldn't cancel scheduled override: %s", err.Error())
+		}
+		if cancelled == nil {
+			fmt.Println("No override scheduled")
+			return
+		}
+		fmt.Printf(
+			"Cancelled override scheduled at height %d on %s by process %d\n",
+			cancelled.Height, cancelled.ScheduledAt.Format(time.RFC3339), cancelled.PID,
 		)
+	},
+}
+
+// scheduleOverride records the override & waits for its target height,
+// then submits it for the validators selected from the validator set at that height
+func scheduleOverride(ctx context.Context, cfg *config.Config, masterClient *internal.ShareGeneratorClient, schedule *internal.ScheduledOverride, skipPrompt, dryRun bool, diffFormat string) error {
+	height, err := masterClient.CosmosClient.GetChainHeight(ctx)
+	if err != nil {
+		return errors.Wrap(err, "error getting chain height")
+	}
+	if schedule.Height <= height {
+		return errors.Errorf("target height %d is not after the latest height %d", schedule.Height, height)
+	}
+
+	if !skipPrompt && !confirm(fmt.Sprintf("Override the active pub key at height %d?", schedule.Height)) {
+		return errors.New("aborted")
+	}
+
+	path, err := internal.DefaultScheduledOverridePath()
+	if err != nil {
+		return err
+	}
+	if err = schedule.Save(path); err != nil {
+		return err
+	}
+	defer func() {
+		if err := schedule.Remove(path); err != nil {
+			log.Printf("Unable to remove scheduled override: %s", err.Error())
+		}
+	}()
+
+	log.Printf("Override scheduled at height %d, cancel it with: override cancel\n", schedule.Height)
+
+	validators, removed, err := masterClient.WaitForScheduledOverride(ctx, cfg, schedule, path)
+	if err != nil {
+		return err
+	}
+
+	if len(removed) > 0 {
+		fmt.Println("Validator(s) removed at the target height:")
+		for _, v := range removed {
+			fmt.Printf("'%s': %s\n", internal.ValidatorLabel(v), v.Address)
+		}
+	}
 
+	// The active pub key may have been rotated while waiting
+	holders, activeValidators, err := masterClient.CosmosClient.GetCurrentPubKeyHolders(ctx)
+	if err != nil {
+		log.Printf("Couldn't get validators info from current public key: %s", err.Error())
+	} else {
+		printOverrideDiff(ctx, masterClient, holders, activeValidators, validators, diffFormat)
+	}
+
+	// Cancel may have been issued while the validators were selected & the shares generated
+	return submitOverride(ctx, masterClient, validators, dryRun, func() error {
+		active, err := schedule.Active(path)
 		if err != nil {
-			log.Printf("Error broadcasting tx: %s", err.Error())
-		} else {
-			log.Printf("Tx Broadcasted: %s", txResp.TxHash)
+			return err
 		}
-	},
+		if !active {
+			return internal.ErrScheduledOverrideCancelled
+		}
+		return nil
+	})
+}
+
+// printOverrideDiff shows what the override changes compared to the active pub key holders
+func printOverrideDiff(ctx context.Context, masterClient *internal.ShareGeneratorClient, holders []cosmosClient.ValidatorPubInfo, activeValidators uint64, validators []cosmosClient.ValidatorPubInfo, format string) {
+	diff, err := masterClient.DiffOverride(ctx, holders, validators, activeValidators)
+	if err == nil {
+		err = diff.Write(os.Stdout, format)
+	}
+	if err != nil {
+		log.Printf("Couldn't compute override diff: %s", err.Error())
+		fmt.Printf("New pub key for %d validators, threshold: %d\n", len(validators), internal.Threshold(len(validators)))
+	}
+	fmt.Println("================")
+}
+
+// submitOverride generates the pub key for the validators & overrides the active one with it,
+// ready is checked right before the tx is submitted when not nil. An error is returned unless the tx is included
+// & succeeded, so the command exits non-zero
+func submitOverride(ctx context.Context, masterClient *internal.ShareGeneratorClient, validators []cosmosClient.ValidatorPubInfo, dryRun bool, ready func() error) error {
+	txMsg, generatedResult, err := masterClient.NewOverrideLatestPubkeyMsg(validators)
+	if err != nil {
+		return errors.Wrap(err, "failed to override latest pubkey")
+	}
+
+	if dryRun {
+		return errors.Wrap(masterClient.ReportDryRun(ctx, txMsg, generatedResult), "dry run failed")
+	}
+
+	if ready != nil {
+		if err = ready(); err != nil {
+			return err
+		}
+	}
+
+	txResp, err := masterClient.CosmosClient.SubmitTx(
+		ctx,
+		txMsg,
+		true,
+		time.Second,
+	)
+
+	if err != nil {
+		return errors.Wrap(err, "error submitting override latest pubkey tx")
+	}
+	if txResp.TxResponse.Code != 0 {
+		return errors.Errorf(
+			"override latest pubkey tx %s failed with code %d: %s",
+			txResp.TxResponse.TxHash, txResp.TxResponse.Code, txResp.TxResponse.RawLog,
+		)
+	}
+	log.Printf("Override latest pubkey tx included: %s", txResp.TxResponse.TxHash)
+	return nil
+}
+
+// confirm asks a yes / no question on stdin, anything but yes is a no
+func confirm(question string) bool {
+	var answer string
+	fmt.Printf("%s [y/N]: ", question)
+	_, _ = fmt.Scanln(&answer)
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
 }
 
 func init() {
+	overrideCmd.Flags().Bool("dry-run", false, "Generate the new pub key up to the gas simulation, without broadcasting")
+	overrideCmd.Flags().StringSlice("include", nil, "Only keep the given validators, by index, address or moniker")
+	overrideCmd.Flags().StringSlice("exclude", nil, "Remove the given validators, by index, address or moniker")
+	overrideCmd.Flags().String("plan-out", "", "Write the selected validators to a plan file for review instead of overriding")
+	overrideCmd.Flags().String("plan-in", "", "Override with the validators of a reviewed plan file")
+	overrideCmd.Flags().BoolP("yes", "y", false, "Skip the confirmation prompt")
+	overrideCmd.MarkFlagsMutuallyExclusive("plan-in", "plan-out")
+	overrideCmd.MarkFlagsMutuallyExclusive("plan-in", "include")
+	overrideCmd.MarkFlagsMutuallyExclusive("plan-in", "exclude")
+	overrideCmd.Flags().Int64("at-height", 0, "Wait for the given block height & override with the validators selected at that height")
+	overrideCmd.MarkFlagsMutuallyExclusive("at-height", "plan-out")
+	overrideCmd.Flags().String("diff-format", internal.DiffFormatTable, "Format of the diff with the active pub key holders: table or json")
+	overrideCmd.AddCommand(overrideCancelCmd)
 	rootCmd.AddCommand(overrideCmd)
 }
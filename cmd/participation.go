@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"ShareGenerationClient/config"
+	"ShareGenerationClient/internal"
+	"ShareGenerationClient/pkg/cosmosClient"
+	"fmt"
+	"github.com/spf13/cobra"
+)
+
+// participationCmd represents the participation command
+var participationCmd = &cobra.Command{
+	Use:   "participation",
+	Short: "Show the keyshare submissions of the active pub key holders",
+	Long:  `Show how many keyshares every active pub key holder submitted over the latest blocks`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.ReadConfigFromFile()
+		if err != nil {
+			fmt.Printf("Error loading config from file: %s\n", err.Error())
+			return
+		}
+
+		window, _ := cmd.Flags().GetUint64("window")
+		if window > 0 {
+			cfg.Participation.Window = window
+		}
+
+		ctx := cmd.Context()
+
+		cClient, err := cosmosClient.NewCosmosClient(ctx, cfg.GetGRPCEndpoint(), cfg.PrivateKey, cfg.FairyRingNode.ChainID)
+		if err != nil {
+			fmt.Printf("Error creating cosmos client: %s\n", err.Error())
+			return
+		}
+		defer cClient.Close()
+
+		height, err := cClient.GetChainHeight(ctx)
+		if err != nil {
+			fmt.Printf("Error getting chain height: %s\n", err.Error())
+			return
+		}
+
+		monitor := internal.NewParticipationMonitor(cfg, cClient)
+		if err = monitor.ObserveUpTo(ctx, height); err != nil {
+			fmt.Printf("Error getting keyshare submissions: %s\n", err.Error())
+			return
+		}
+
+		monikers := make(map[string]string)
+		validatorsInfo, err := cClient.GetCurrentPubKeyValidatorsInfo(ctx)
+		if err != nil {
+			fmt.Printf("Unable to get validators description: %s\n", err.Error())
+		}
+		for _, v := range validatorsInfo {
+			if v.Description == nil {
+				continue
+			}
+			if len(v.Authorizing) > 0 {
+				monikers[v.Authorizing] = v.Description.Moniker
+			} else {
+				monikers[v.Address] = v.Description.Moniker
+			}
+		}
+
+		report := monitor.Report()
+		if len(report) == 0 {
+			fmt.Println("No active pub key holders found")
+			return
+		}
+
+		fmt.Printf("Keyshares submitted by %d active pub key holders up to height %d (%d blocks):\n", len(report), monitor.LastHeight(), monitor.Window())
+		for i, p := range report {
+			fmt.Printf("[%d] '%s': %s | Submitted: %d / %d | Rate: %.2f%%\n", i, monikers[p.Address], p.Address, p.Submitted, p.Observed, p.Rate*100)
+		}
+	},
+}
+
+func init() {
+	participationCmd.Flags().Uint64("window", 0, "Number of latest blocks to report on, the config participation window if 0")
+	rootCmd.AddCommand(participationCmd)
+}
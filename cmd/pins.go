@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"ShareGenerationClient/internal"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"sort"
+)
+
+// pinsCmd represents the pins command
+var pinsCmd = &cobra.Command{
+	Use:   "pins",
+	Short: "Manage pinned validators encryption keys",
+	Long:  `Manage validators encryption keys pinned on first use`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			_ = cmd.Help()
+		}
+	},
+}
+
+// pinsListCmd represents the pins list command
+var pinsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List pinned & pending validators encryption keys",
+	Long:  `List pinned & pending validators encryption keys`,
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := loadPinStore()
+		if err != nil {
+			fmt.Printf("Error loading pin store: %s\n", err.Error())
+			return
+		}
+
+		fmt.Printf("Pinned validators: %d\n", len(store.Pinned))
+		for _, v := range sortedPins(store.Pinned) {
+			fmt.Printf("%s: %s | Share holder: %s | Since: %s\n", v.Validator, v.PublicKey, v.Target, v.FirstSeen)
+		}
+
+		fmt.Println("================")
+
+		fmt.Printf("Pending changes: %d\n", len(store.Pending))
+		for _, v := range sortedPins(store.Pending) {
+			pinned := store.Pinned[v.Validator]
+			fmt.Printf("%s: %s => %s | Share holder: %s => %s | Seen: %s\n", v.Validator, pinned.PublicKey, v.PublicKey, pinned.Target, v.Target, v.FirstSeen)
+		}
+	},
+}
+
+// pinsAcceptCmd represents the pins accept command
+var pinsAcceptCmd = &cobra.Command{
+	Use:   "accept [validator...]",
+	Short: "Accept pending validators encryption key changes",
+	Long:  `Accept pending validators encryption key changes, the new keys will be used for next generated shares`,
+	Run: func(cmd *cobra.Command, args []string) {
+		path, err := internal.DefaultPinStorePath()
+		if err != nil {
+			fmt.Printf("Error loading pin store: %s\n", err.Error())
+			return
+		}
+
+		all, _ := cmd.Flags().GetBool("all")
+		err = internal.UpdatePinStore(path, func(store *internal.PinStore) error {
+			validators := args
+			if all {
+				validators = make([]string, 0, len(store.Pending))
+				for v := range store.Pending {
+					validators = append(validators, v)
+				}
+			}
+
+			if len(validators) == 0 {
+				return errors.New("no validator given, specify validators address or use --all")
+			}
+
+			for _, v := range validators {
+				if err := store.Accept(v); err != nil {
+					return errors.Wrap(err, "error accepting change")
+				}
+				fmt.Printf("Accepted validator %s new encryption key %s\n", v, store.Pinned[v].PublicKey)
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Printf("Pin store not updated: %s\n", err.Error())
+		}
+	},
+}
+
+func loadPinStore() (*internal.PinStore, error) {
+	path, err := internal.DefaultPinStorePath()
+	if err != nil {
+		return nil, err
+	}
+	return internal.LoadPinStore(path)
+}
+
+func sortedPins(pins map[string]internal.ValidatorPin) []internal.ValidatorPin {
+	result := make([]internal.ValidatorPin, 0, len(pins))
+	for _, v := range pins {
+		result = append(result, v)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Validator < result[j].Validator
+	})
+	return result
+}
+
+func init() {
+	rootCmd.AddCommand(pinsCmd)
+
+	pinsCmd.AddCommand(pinsListCmd)
+	pinsCmd.AddCommand(pinsAcceptCmd)
+
+	pinsAcceptCmd.Flags().Bool("all", false, "Accept all pending changes")
+}
@@ -3,8 +3,13 @@ package cmd
 import (
 	"ShareGenerationClient/config"
 	"ShareGenerationClient/internal"
+	"context"
 	"fmt"
 	"github.com/spf13/cobra"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
 // startCmd represents the start command
@@ -16,12 +21,27 @@ var startCmd = &cobra.Command{
 		cfg, err := config.ReadConfigFromFile()
 		if err != nil {
 			fmt.Printf("Error loading config from file: %s\n", err.Error())
-			return
+			os.Exit(internal.ExitCodeError)
 		}
-		internal.ShareGenerationClient(cfg)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-ctx.Done()
+			// Restore default signal handling, so a second signal stops the client immediately
+			stop()
+			log.Println("Shutting down, signal again to force...")
+		}()
+
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		if dryRun {
+			os.Exit(internal.DryRun(ctx, cfg))
+		}
+
+		os.Exit(internal.ShareGenerationClient(ctx, cfg))
 	},
 }
 
 func init() {
+	startCmd.Flags().Bool("dry-run", false, "Run the pub key generation once up to the gas simulation, without broadcasting")
 	rootCmd.AddCommand(startCmd)
 }
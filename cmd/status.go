@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"ShareGenerationClient/config"
+	"ShareGenerationClient/internal"
+	"ShareGenerationClient/pkg/cosmosClient"
+	"fmt"
+	"github.com/spf13/cobra"
+	"strings"
+)
+
+// statusCmd represents the status command
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the pub keys status and expiry ETAs",
+	Long:  `Show the active & queued pub keys, when they expire and when the next queued pub key is generated`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.ReadConfigFromFile()
+		if err != nil {
+			fmt.Printf("Error loading config from file: %s\n", err.Error())
+			return
+		}
+
+		ctx := cmd.Context()
+
+		cClient, err := cosmosClient.NewCosmosClient(ctx, cfg.GetGRPCEndpoint(), cfg.PrivateKey, cfg.FairyRingNode.ChainID)
+		if err != nil {
+			fmt.Printf("Error creating cosmos client: %s\n", err.Error())
+			return
+		}
+		defer cClient.Close()
+
+		var estimator internal.BlockTimeEstimator
+		if err = estimator.Seed(ctx, cClient); err != nil {
+			fmt.Printf("Error estimating block time: %s\n", err.Error())
+			return
+		}
+
+		height, err := cClient.GetChainHeight(ctx)
+		if err != nil {
+			fmt.Printf("Error getting chain height: %s\n", err.Error())
+			return
+		}
+
+		res, err := cClient.GetActivePubKey(ctx)
+		if err != nil && !strings.Contains(err.Error(), "Active Public Key does not exists") {
+			fmt.Printf("Error getting pub keys: %s\n", err.Error())
+			return
+		}
+
+		plan := internal.NewExpirySchedule(cfg).Plan(height, res, estimator.Estimate())
+
+		fmt.Printf("Chain Height: %d | Block Time: %s\n", height, estimator.Estimate())
+
+		if plan.HasActive {
+			fmt.Printf(
+				"Active Pub Key: %s\n  Expires at: %d | Blocks left: %d | ETA: %s\n",
+				res.ActivePubkey.PublicKey, res.ActivePubkey.Expiry, plan.BlocksLeft, estimator.FormatETA(plan.BlocksLeft),
+			)
+		} else {
+			fmt.Println("Active Pub Key: none")
+		}
+
+		if plan.HasQueued {
+			fmt.Printf(
+				"Queued Pub Key: %s\n  Expires at: %d | ETA: %s\n",
+				res.QueuedPubkey.PublicKey, res.QueuedPubkey.Expiry, estimator.FormatETA(int64(res.QueuedPubkey.Expiry)-height),
+			)
+		} else {
+			fmt.Println("Queued Pub Key: none")
+		}
+
+		switch {
+		case plan.Generate:
+			fmt.Println("Queued Pub Key generation: due now")
+		case plan.NextCheck > 0 && !plan.HasQueued:
+			fmt.Printf("Queued Pub Key generation: at height %d | ETA: %s\n", plan.NextCheck, estimator.FormatETA(plan.NextCheck-height))
+		}
+
+		if plan.Alert {
+			fmt.Printf("ALERT: Active Pub Key expires in %d blocks and there is no queued pub key\n", plan.BlocksLeft)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+}
@@ -3,9 +3,11 @@ package config
 import (
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"path/filepath"
 	"strconv"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -14,7 +16,46 @@ const (
 	DefaultFolderName    = ".ShareGenerationClient"
 	DefaultChainID       = "fairyring-testnet-1"
 	DefaultDenom         = "ufair"
-	DefaultCheckInterval = 50
+	DefaultCheckInterval = "50"
+
+	DefaultTrustingPeriod = 168 * time.Hour
+	DefaultSignMode       = "direct"
+	DefaultGasPrice       = "0.025"
+	DefaultFeeBumpFactor  = "1.5"
+	DefaultFeeMaxBumps    = 3
+
+	DefaultTxTimeoutBlocks   = 20
+	DefaultTxMaxRebroadcasts = 3
+
+	DefaultRetryInitialBackoff = time.Second
+	DefaultRetryMaxBackoff     = 30 * time.Second
+	DefaultRetryBudget         = 5
+
+	DefaultWebsocketStallTimeout = time.Minute
+	DefaultWebsocketPollInterval = 5 * time.Second
+
+	DefaultShutdownTimeout = 30 * time.Second
+
+	// DefaultGenerateBeforeExpiryBlocks of 0, without GenerateBeforeExpiry, generates the queued pub key
+	// as soon as there is none
+	DefaultGenerateBeforeExpiryBlocks = 0
+	DefaultAlertBeforeExpiryBlocks    = 50
+
+	DefaultAutoOverrideMinOverlap = 0.8
+
+	DefaultParticipationWindow = 100
+
+	DefaultCanaryInterval     = 10 * time.Minute
+	DefaultCanaryTargetBlocks = 5
+	DefaultCanaryVerifyBlocks = 10
+
+	DefaultKeyMonitorMaxLagBlocks      = 5
+	DefaultKeyMonitorAlertAfterMissing = 3
+
+	// PinPolicyManual pauses generation until validators encryption key changes are accepted by the operator
+	PinPolicyManual = "manual"
+	// PinPolicyAccept accepts validators encryption key changes automatically after logging them
+	PinPolicyAccept = "accept"
 )
 
 type Node struct {
@@ -26,11 +67,90 @@ type Node struct {
 	ChainID  string
 }
 
+// LightClient verifies the validators info returned by the node with proofs,
+// the completeness of the validator set is not verified, verification nodes are needed to detect omissions
+type LightClient struct {
+	Enabled        bool
+	TrustedHeight  int64
+	TrustedHash    string
+	TrustingPeriod time.Duration
+	Witnesses      []string
+}
+
+type Fee struct {
+	GasPrice   string
+	MaxFee     string
+	Granter    string
+	Auto       bool
+	BumpFactor string
+	MaxBumps   uint64
+}
+
+type Tx struct {
+	TimeoutBlocks   uint64
+	MaxRebroadcasts uint64
+}
+
+type Retry struct {
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Budget         uint64
+}
+
+type Websocket struct {
+	StallTimeout time.Duration
+	PollInterval time.Duration
+}
+
+type Schedule struct {
+	GenerateBeforeExpiryBlocks uint64
+	GenerateBeforeExpiry       time.Duration
+	AlertBeforeExpiryBlocks    uint64
+}
+
+type AutoOverride struct {
+	Enabled    bool
+	MinOverlap float64
+}
+
+type Participation struct {
+	Enabled bool
+	Window  uint64
+}
+
+type Canary struct {
+	Enabled      bool
+	Interval     time.Duration
+	TargetBlocks uint64
+	VerifyBlocks uint64
+}
+
+type KeyMonitor struct {
+	Enabled           bool
+	MaxLagBlocks      uint64
+	AlertAfterMissing uint64
+}
+
 type Config struct {
-	FairyRingNode Node
-	CheckInterval uint64
-	PrivateKey    string
-	MetricsPort   uint64
+	FairyRingNode      Node
+	LightClient        LightClient
+	VerificationNodes  []Node
+	VerificationQuorum uint64
+	PinPolicy          string
+	SignMode           string
+	Fee                Fee
+	Tx                 Tx
+	Retry              Retry
+	Websocket          Websocket
+	Schedule           Schedule
+	AutoOverride       AutoOverride
+	Participation      Participation
+	Canary             Canary
+	KeyMonitor         KeyMonitor
+	ShutdownTimeout    time.Duration
+	CheckInterval      string
+	PrivateKey         string
+	MetricsPort        uint64
 }
 
 func ReadConfigFromFile() (*Config, error) {
@@ -48,6 +168,10 @@ func ReadConfigFromFile() (*Config, error) {
 		return nil, err
 	}
 
+	// Settings missing from a config written by an older version fall back to their defaults,
+	// the values set in the file take precedence
+	setInitialConfig(DefaultConfig())
+
 	err = viper.Unmarshal(&cfg)
 	if err != nil {
 		return nil, err
@@ -56,21 +180,67 @@ func ReadConfigFromFile() (*Config, error) {
 	return &cfg, nil
 }
 
+func GetConfigDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, DefaultFolderName), nil
+}
+
 func (c *Config) GetFairyRingNodeURI() string {
 	nodeURI := c.FairyRingNode.Protocol + "://" + c.FairyRingNode.IP + ":" + strconv.FormatUint(c.FairyRingNode.Port, 10)
 	return nodeURI
 }
 
 func (c *Config) GetGRPCEndpoint() string {
-	ep := c.FairyRingNode.IP + ":" + strconv.FormatUint(c.FairyRingNode.GRPCPort, 10)
+	return c.FairyRingNode.GetGRPCEndpoint()
+}
+
+func (n *Node) GetGRPCEndpoint() string {
+	ep := n.IP + ":" + strconv.FormatUint(n.GRPCPort, 10)
 	return ep
 }
 
+// ParseVerificationNode parses a verification node gRPC endpoint like '10.0.0.2:9090'
+func ParseVerificationNode(endpoint string) (Node, error) {
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil || len(host) == 0 {
+		return Node{}, fmt.Errorf("verification node must be a gRPC endpoint like '10.0.0.2:9090', got: '%s'", endpoint)
+	}
+	grpcPort, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return Node{}, fmt.Errorf("invalid verification node gRPC port: '%s'", port)
+	}
+	return Node{IP: host, GRPCPort: grpcPort}, nil
+}
+
+// ParseCheckInterval parses the check interval, either a number of blocks like '50' or a duration like '5m'
+func ParseCheckInterval(interval string) (blocks uint64, duration time.Duration, err error) {
+	if blocks, err = strconv.ParseUint(interval, 10, 64); err == nil {
+		return blocks, 0, nil
+	}
+	if duration, err = time.ParseDuration(interval); err != nil || duration <= 0 {
+		return 0, 0, fmt.Errorf("check interval must be a number of blocks or a positive duration, got: '%s'", interval)
+	}
+	return 0, duration, nil
+}
+
+// GetVerificationQuorum returns the number of nodes, including the main FairyRing node,
+// that must return the same validator set before any share is generated
+func (c *Config) GetVerificationQuorum() uint64 {
+	total := uint64(len(c.VerificationNodes)) + 1
+	if c.VerificationQuorum == 0 || c.VerificationQuorum > total {
+		return total
+	}
+	return c.VerificationQuorum
+}
+
 func (c *Config) SaveConfig() error {
 	updateConfig(*c)
 
 	if err := viper.WriteConfig(); err != nil {
-		fmt.Errorf("failed to write config as : %s", err.Error())
+		return fmt.Errorf("failed to write config as : %s", err.Error())
 	}
 
 	return nil
@@ -112,7 +282,7 @@ func (c *Config) ExportConfig() error {
 	setInitialConfig(*c)
 
 	if err = viper.WriteConfigAs(homeDir + "/" + DefaultFolderName + "/config.yml"); err != nil {
-		fmt.Errorf("failed to write config as : %s", err.Error())
+		return fmt.Errorf("failed to write config as : %s", err.Error())
 	}
 
 	return nil
@@ -128,8 +298,59 @@ func DefaultConfig() Config {
 			Denom:    DefaultDenom,
 			ChainID:  DefaultChainID,
 		},
-		CheckInterval: DefaultCheckInterval,
-		MetricsPort:   2223,
+		LightClient: LightClient{
+			Enabled:        false,
+			TrustingPeriod: DefaultTrustingPeriod,
+			Witnesses:      []string{},
+		},
+		VerificationNodes: []Node{},
+		PinPolicy:         PinPolicyManual,
+		SignMode:          DefaultSignMode,
+		Fee: Fee{
+			GasPrice:   DefaultGasPrice,
+			Auto:       true,
+			BumpFactor: DefaultFeeBumpFactor,
+			MaxBumps:   DefaultFeeMaxBumps,
+		},
+		Tx: Tx{
+			TimeoutBlocks:   DefaultTxTimeoutBlocks,
+			MaxRebroadcasts: DefaultTxMaxRebroadcasts,
+		},
+		Retry: Retry{
+			InitialBackoff: DefaultRetryInitialBackoff,
+			MaxBackoff:     DefaultRetryMaxBackoff,
+			Budget:         DefaultRetryBudget,
+		},
+		Websocket: Websocket{
+			StallTimeout: DefaultWebsocketStallTimeout,
+			PollInterval: DefaultWebsocketPollInterval,
+		},
+		Schedule: Schedule{
+			GenerateBeforeExpiryBlocks: DefaultGenerateBeforeExpiryBlocks,
+			AlertBeforeExpiryBlocks:    DefaultAlertBeforeExpiryBlocks,
+		},
+		AutoOverride: AutoOverride{
+			Enabled:    false,
+			MinOverlap: DefaultAutoOverrideMinOverlap,
+		},
+		Participation: Participation{
+			Enabled: false,
+			Window:  DefaultParticipationWindow,
+		},
+		Canary: Canary{
+			Enabled:      false,
+			Interval:     DefaultCanaryInterval,
+			TargetBlocks: DefaultCanaryTargetBlocks,
+			VerifyBlocks: DefaultCanaryVerifyBlocks,
+		},
+		KeyMonitor: KeyMonitor{
+			Enabled:           true,
+			MaxLagBlocks:      DefaultKeyMonitorMaxLagBlocks,
+			AlertAfterMissing: DefaultKeyMonitorAlertAfterMissing,
+		},
+		ShutdownTimeout: DefaultShutdownTimeout,
+		CheckInterval:   DefaultCheckInterval,
+		MetricsPort:     2223,
 	}
 }
 
@@ -141,8 +362,56 @@ func updateConfig(c Config) {
 	viper.Set("FairyRingNode.denom", c.FairyRingNode.Denom)
 	viper.Set("FairyRingNode.chainID", c.FairyRingNode.ChainID)
 
+	viper.Set("LightClient.enabled", c.LightClient.Enabled)
+	viper.Set("LightClient.trustedHeight", c.LightClient.TrustedHeight)
+	viper.Set("LightClient.trustedHash", c.LightClient.TrustedHash)
+	viper.Set("LightClient.trustingPeriod", c.LightClient.TrustingPeriod.String())
+	viper.Set("LightClient.witnesses", c.LightClient.Witnesses)
+
+	viper.Set("VerificationNodes", c.VerificationNodes)
+	viper.Set("VerificationQuorum", c.VerificationQuorum)
+	viper.Set("PinPolicy", c.PinPolicy)
+	viper.Set("SignMode", c.SignMode)
+
+	viper.Set("Fee.gasPrice", c.Fee.GasPrice)
+	viper.Set("Fee.maxFee", c.Fee.MaxFee)
+	viper.Set("Fee.granter", c.Fee.Granter)
+	viper.Set("Fee.auto", c.Fee.Auto)
+	viper.Set("Fee.bumpFactor", c.Fee.BumpFactor)
+	viper.Set("Fee.maxBumps", c.Fee.MaxBumps)
+
+	viper.Set("Tx.timeoutBlocks", c.Tx.TimeoutBlocks)
+	viper.Set("Tx.maxRebroadcasts", c.Tx.MaxRebroadcasts)
+
+	viper.Set("Retry.initialBackoff", c.Retry.InitialBackoff.String())
+	viper.Set("Retry.maxBackoff", c.Retry.MaxBackoff.String())
+	viper.Set("Retry.budget", c.Retry.Budget)
+
+	viper.Set("Websocket.stallTimeout", c.Websocket.StallTimeout.String())
+	viper.Set("Websocket.pollInterval", c.Websocket.PollInterval.String())
+
+	viper.Set("Schedule.generateBeforeExpiryBlocks", c.Schedule.GenerateBeforeExpiryBlocks)
+	viper.Set("Schedule.generateBeforeExpiry", c.Schedule.GenerateBeforeExpiry.String())
+	viper.Set("Schedule.alertBeforeExpiryBlocks", c.Schedule.AlertBeforeExpiryBlocks)
+
+	viper.Set("AutoOverride.enabled", c.AutoOverride.Enabled)
+	viper.Set("AutoOverride.minOverlap", c.AutoOverride.MinOverlap)
+
+	viper.Set("Participation.enabled", c.Participation.Enabled)
+	viper.Set("Participation.window", c.Participation.Window)
+
+	viper.Set("Canary.enabled", c.Canary.Enabled)
+	viper.Set("Canary.interval", c.Canary.Interval.String())
+	viper.Set("Canary.targetBlocks", c.Canary.TargetBlocks)
+	viper.Set("Canary.verifyBlocks", c.Canary.VerifyBlocks)
+
+	viper.Set("KeyMonitor.enabled", c.KeyMonitor.Enabled)
+	viper.Set("KeyMonitor.maxLagBlocks", c.KeyMonitor.MaxLagBlocks)
+	viper.Set("KeyMonitor.alertAfterMissing", c.KeyMonitor.AlertAfterMissing)
+
 	viper.Set("PrivateKey", c.PrivateKey)
 	viper.Set("CheckInterval", c.CheckInterval)
+	viper.Set("ShutdownTimeout", c.ShutdownTimeout.String())
 	viper.Set("MetricsPort", c.MetricsPort)
 }
 
@@ -154,7 +423,55 @@ func setInitialConfig(c Config) {
 	viper.SetDefault("FairyRingNode.denom", c.FairyRingNode.Denom)
 	viper.SetDefault("FairyRingNode.chainID", c.FairyRingNode.ChainID)
 
+	viper.SetDefault("LightClient.enabled", c.LightClient.Enabled)
+	viper.SetDefault("LightClient.trustedHeight", c.LightClient.TrustedHeight)
+	viper.SetDefault("LightClient.trustedHash", c.LightClient.TrustedHash)
+	viper.SetDefault("LightClient.trustingPeriod", c.LightClient.TrustingPeriod.String())
+	viper.SetDefault("LightClient.witnesses", c.LightClient.Witnesses)
+
+	viper.SetDefault("VerificationNodes", c.VerificationNodes)
+	viper.SetDefault("VerificationQuorum", c.VerificationQuorum)
+	viper.SetDefault("PinPolicy", c.PinPolicy)
+	viper.SetDefault("SignMode", c.SignMode)
+
+	viper.SetDefault("Fee.gasPrice", c.Fee.GasPrice)
+	viper.SetDefault("Fee.maxFee", c.Fee.MaxFee)
+	viper.SetDefault("Fee.granter", c.Fee.Granter)
+	viper.SetDefault("Fee.auto", c.Fee.Auto)
+	viper.SetDefault("Fee.bumpFactor", c.Fee.BumpFactor)
+	viper.SetDefault("Fee.maxBumps", c.Fee.MaxBumps)
+
+	viper.SetDefault("Tx.timeoutBlocks", c.Tx.TimeoutBlocks)
+	viper.SetDefault("Tx.maxRebroadcasts", c.Tx.MaxRebroadcasts)
+
+	viper.SetDefault("Retry.initialBackoff", c.Retry.InitialBackoff.String())
+	viper.SetDefault("Retry.maxBackoff", c.Retry.MaxBackoff.String())
+	viper.SetDefault("Retry.budget", c.Retry.Budget)
+
+	viper.SetDefault("Websocket.stallTimeout", c.Websocket.StallTimeout.String())
+	viper.SetDefault("Websocket.pollInterval", c.Websocket.PollInterval.String())
+
+	viper.SetDefault("Schedule.generateBeforeExpiryBlocks", c.Schedule.GenerateBeforeExpiryBlocks)
+	viper.SetDefault("Schedule.generateBeforeExpiry", c.Schedule.GenerateBeforeExpiry.String())
+	viper.SetDefault("Schedule.alertBeforeExpiryBlocks", c.Schedule.AlertBeforeExpiryBlocks)
+
+	viper.SetDefault("AutoOverride.enabled", c.AutoOverride.Enabled)
+	viper.SetDefault("AutoOverride.minOverlap", c.AutoOverride.MinOverlap)
+
+	viper.SetDefault("Participation.enabled", c.Participation.Enabled)
+	viper.SetDefault("Participation.window", c.Participation.Window)
+
+	viper.SetDefault("Canary.enabled", c.Canary.Enabled)
+	viper.SetDefault("Canary.interval", c.Canary.Interval.String())
+	viper.SetDefault("Canary.targetBlocks", c.Canary.TargetBlocks)
+	viper.SetDefault("Canary.verifyBlocks", c.Canary.VerifyBlocks)
+
+	viper.SetDefault("KeyMonitor.enabled", c.KeyMonitor.Enabled)
+	viper.SetDefault("KeyMonitor.maxLagBlocks", c.KeyMonitor.MaxLagBlocks)
+	viper.SetDefault("KeyMonitor.alertAfterMissing", c.KeyMonitor.AlertAfterMissing)
+
 	viper.SetDefault("PrivateKey", c.PrivateKey)
 	viper.SetDefault("CheckInterval", c.CheckInterval)
+	viper.SetDefault("ShutdownTimeout", c.ShutdownTimeout.String())
 	viper.SetDefault("MetricsPort", c.MetricsPort)
 }
@@ -4,17 +4,14 @@ import (
 	"ShareGenerationClient/config"
 	"ShareGenerationClient/pkg/cosmosClient"
 	"context"
-	"encoding/hex"
 	"fmt"
-	"github.com/Fairblock/fairyring/x/keyshare/types"
-	tmclient "github.com/cometbft/cometbft/rpc/client/http"
-	tmtypes "github.com/cometbft/cometbft/types"
+	peptypes "github.com/Fairblock/fairyring/x/pep/types"
+	"github.com/cosmos/cosmos-sdk/types/tx"
+	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"log"
-	"math"
-	"math/big"
 	"net/http"
 	"strings"
 	"time"
@@ -32,138 +29,236 @@ var (
 	})
 )
 
-func ShareGenerationClient(cfg *config.Config) {
+// ShareGenerationClient runs the client until ctx is done, and returns the process exit code
+func ShareGenerationClient(ctx context.Context, cfg *config.Config) int {
 
-	privateKey := cfg.PrivateKey
-	gRPCEndpoint := cfg.GetGRPCEndpoint()
-	checkInterval := cfg.CheckInterval
+	checkIntervalBlocks, checkIntervalDuration, err := config.ParseCheckInterval(cfg.CheckInterval)
+	if err != nil {
+		log.Printf("Invalid check interval: %s\n", err.Error())
+		return ExitCodeError
+	}
+	retryPolicy := NewRetryPolicy(cfg)
 
-	cClient, err := cosmosClient.NewCosmosClient(gRPCEndpoint, privateKey, cfg.FairyRingNode.ChainID)
+	masterClient, err := NewShareGeneratorClient(ctx, cfg)
 	if err != nil {
-		log.Fatalf("Couldn't create cosmos client: %s", err.Error())
+		log.Printf("Unable to create client: %s\n", err.Error())
+		return ExitCodeError
+	}
+	defer masterClient.Close()
+
+	if err = masterClient.ResolveRecordedInFlightTx(ctx); err != nil {
+		log.Printf("Unable to resolve in flight tx recorded on last shutdown: %s\n", err.Error())
 	}
 
-	masterClient := ShareGeneratorClient{
-		CosmosClient: cClient,
+	var estimator BlockTimeEstimator
+	if err = estimator.Seed(ctx, masterClient.CosmosClient); err != nil {
+		log.Printf("Unable to estimate block time from recent blocks: %s\n", err.Error())
 	}
 
-	client, err := tmclient.New(
-		cfg.GetFairyRingNodeURI(),
-		"/websocket",
-	)
+	heights := make(chan Block, 1)
+	triggers := make(chan CheckTrigger, 1)
+	watcher := NewHeightWatcher(cfg, masterClient.CosmosClient)
+	watcher.SetTriggers(triggers)
+	go watcher.Run(ctx, heights)
 
-	if err = client.Start(); err != nil {
-		log.Fatal(err)
+	var participationBlocks chan Block
+	if cfg.Participation.Enabled {
+		participationBlocks = make(chan Block, 1)
+		go NewParticipationMonitor(cfg, masterClient.CosmosClient).Run(ctx, participationBlocks)
 	}
 
-	out, err := client.Subscribe(context.Background(), "", "tm.event = 'NewBlockHeader'")
-	if err != nil {
-		log.Fatal(err)
+	var keyMonitorBlocks chan Block
+	if cfg.KeyMonitor.Enabled {
+		keyMonitorBlocks = make(chan Block, 1)
+		go NewKeyAvailabilityMonitor(cfg, masterClient.CosmosClient).Run(ctx, keyMonitorBlocks)
+	}
+
+	if cfg.Canary.Enabled {
+		canary, err := NewEncryptionCanary(cfg, masterClient.CosmosClient)
+		if err != nil {
+			log.Printf("Unable to create encryption canary: %s\n", err.Error())
+		} else {
+			go canary.Run(ctx)
+		}
 	}
 
-	defer client.Stop()
-	var blockPassed uint64 = math.MaxUint64
+	var nextCheck int64
 
-	log.Printf("Client Started, checking pub key status every %d block...\n", checkInterval)
+	log.Printf("Client Started, checking pub key status on keyshare module events, at the pub key expiry schedule and at least every %s...\n", cfg.CheckInterval)
 
-	http.Handle("/metrics", promhttp.Handler())
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	metricsServer := &http.Server{Addr: fmt.Sprintf(":%d", cfg.MetricsPort), Handler: mux}
 	log.Printf("MetricsPort: %d\n", cfg.MetricsPort)
-	go http.ListenAndServe(fmt.Sprintf(":%d", cfg.MetricsPort), nil)
+	go func() {
+		if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Metrics server stopped: %s\n", err.Error())
+		}
+	}()
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), masterClient.ShutdownTimeout)
+		defer cancel()
+		if err := metricsServer.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Unable to stop metrics server: %s\n", err.Error())
+		}
+	}()
 
+	exitCode := ExitCodeOK
+	var lastHeight int64
 	for {
+		var height int64
 		select {
-		case result := <-out:
-			newBlockHeader := result.Data.(tmtypes.EventDataNewBlockHeader)
-
-			height := newBlockHeader.Header.Height
-
-			if blockPassed != math.MaxUint64 {
-				blockPassed++
-				if blockPassed < checkInterval {
-					continue
-				}
-				blockPassed = 0
-			} else {
-				blockPassed = 0
+		case block, ok := <-heights:
+			if !ok {
+				log.Println("Client stopped")
+				return exitCode
 			}
+			height = block.Height
+			lastHeight = height
+			estimator.Observe(block)
 
-			fmt.Println("")
-			log.Printf("Latest Block Height: %d | Checking Pub Key status...\n", height)
+			// Skipped blocks are caught up on the next one
+			select {
+			case participationBlocks <- block:
+			default:
+			}
+			select {
+			case keyMonitorBlocks <- block:
+			default:
+			}
 
-			res, err := masterClient.CosmosClient.GetActivePubKey()
-			if err != nil && !strings.Contains(err.Error(), "Active Public Key does not exists") {
-				log.Fatal("Error while querying pub key:", err)
+			// Heights may be skipped while polling, so checks are scheduled at a height instead of counting blocks
+			if height < nextCheck {
+				continue
 			}
 
-			if res == nil || (len(res.QueuedPubkey.PublicKey) == 0 && len(res.QueuedPubkey.Creator) == 0) {
-				log.Println("Queued Pub Key Not found, sending setup request...")
-				validatorsPubInfos, err := masterClient.CosmosClient.GetAllValidatorsPubInfos()
-				if err != nil {
-					log.Fatalf("error getting all validators public infos: %s\n", err.Error())
-				}
-				generatedResult := masterClient.Generate(validatorsPubInfos)
-				if generatedResult == nil {
-					log.Fatal("Generate result is empty")
-				}
-
-				n := len(generatedResult.EncryptedKeyShares)
-
-				encShares := make([]*types.EncryptedKeyshare, n)
-
-				for _, v := range generatedResult.EncryptedKeyShares {
-					indexByte, _ := hex.DecodeString(v.Index.String())
-					indexInt := big.NewInt(0).SetBytes(indexByte).Uint64()
-					encShares[indexInt-1] = &types.EncryptedKeyshare{
-						Data:      v.EncShare,
-						Validator: v.ValidatorAddress,
-					}
-				}
-
-				txMsg := types.MsgCreateLatestPubkey{
-					Creator:            masterClient.CosmosClient.GetAddress(),
-					PublicKey:          generatedResult.MasterPublicKey,
-					Commitments:        generatedResult.Commitments,
-					NumberOfValidators: uint64(n),
-					EncryptedKeyshares: encShares,
-				}
-
-				if err = txMsg.ValidateBasic(); err != nil {
-					log.Fatalf("Failed to submit latest pubkey, validate basic failed: %s", err.Error())
-				}
-
-				if err = masterClient.CosmosClient.UpdateClientAccountInfo(); err != nil {
-					log.Printf("Unable to update client account info: %s", err.Error())
-				}
-
-				txResp, err := masterClient.CosmosClient.BroadcastTx(
-					&txMsg,
-					true,
-				)
-				if err != nil {
-					log.Printf("Error broadcasting tx: %s", err.Error())
-					failedShareGenerated.Inc()
-					break
-				} else {
-					log.Printf("Tx Broadcasted: %s", txResp.TxHash)
-				}
-
-				finalTxResp, err := masterClient.CosmosClient.WaitForTx(txResp.TxHash, time.Second)
-				if err != nil {
-					log.Printf("Create latest pubkey tx failed: %s\n", err.Error())
-					break
-				}
-
-				if finalTxResp.TxResponse.Code != 0 {
-					log.Printf("Create latest pubkey tx failed: %s\n", finalTxResp.TxResponse.RawLog)
-					failedShareGenerated.Inc()
-					break
-				}
-				validShareGenerated.Inc()
-			} else {
-				log.Println("Pub Keys Found !")
-				log.Printf("Active Pub Key: %s | Expries at: %d\n", res.ActivePubkey.PublicKey, res.ActivePubkey.Expiry)
-				log.Printf("Queued Pub Key: %s | Expries at: %d\n", res.QueuedPubkey.PublicKey, res.QueuedPubkey.Expiry)
+			fmt.Println("")
+			log.Printf("Latest Block Height: %d | Block time: %s | Checking Pub Key status...\n", height, estimator.Estimate())
+		case trigger := <-triggers:
+			height = max(trigger.Height, lastHeight)
+
+			fmt.Println("")
+			log.Printf("Event %s at height %d | Checking Pub Key status...\n", trigger.Event, trigger.Height)
+		}
+
+		retry := retryPolicy.newRetrier()
+		next, err := masterClient.checkPubKey(ctx, retry, height, &estimator)
+		if err == nil && masterClient.OverridePolicy.Enabled {
+			err = masterClient.checkValidatorSetDrift(ctx, retry)
+		}
+
+		// Check interval is a safety net in case keyshare module events are missed
+		var intervalNext int64
+		if checkIntervalDuration > 0 {
+			intervalNext = height + estimator.BlocksIn(checkIntervalDuration)
+		} else {
+			intervalNext = height + int64(checkIntervalBlocks)
+		}
+		if next <= height || next > intervalNext {
+			next = intervalNext
+		}
+		nextCheck = next
+		nextCheckHeight.Set(float64(nextCheck))
+
+		var inFlight *cosmosClient.InFlightTxError
+		if errors.As(err, &inFlight) {
+			if err = masterClient.FinishInFlightTx(inFlight); err != nil {
+				log.Printf("ALERT: %s\n", err.Error())
+				exitCode = ExitCodeTxInFlight
 			}
+			continue
+		}
+
+		if ctx.Err() == nil {
+			recordCheckResult(err)
+		}
+	}
+}
+
+// checkPubKey submits a new queued pub key if there is none and the active one is close to expiry,
+// and returns the height of the next check, 0 for the check interval.
+// Transient errors are retried until the retry budget of the check is spent
+func (sgc *ShareGeneratorClient) checkPubKey(ctx context.Context, retry *retrier, height int64, estimator *BlockTimeEstimator) (int64, error) {
+	var res *peptypes.QueryPubkeyResponse
+	err := retry.Do(ctx, "querying pub key", func() error {
+		var err error
+		res, err = sgc.CosmosClient.GetActivePubKey(ctx)
+		if err != nil && strings.Contains(err.Error(), "Active Public Key does not exists") {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	plan := sgc.Schedule.Plan(height, res, estimator.Estimate())
+	recordSchedulePlan(plan, estimator)
+
+	if plan.HasActive {
+		log.Printf(
+			"Active Pub Key: %s | Expries at: %d | Blocks left: %d | ETA: %s\n",
+			res.ActivePubkey.PublicKey, res.ActivePubkey.Expiry, plan.BlocksLeft, estimator.FormatETA(plan.BlocksLeft),
+		)
+	}
+	if plan.HasQueued {
+		log.Printf(
+			"Queued Pub Key: %s | Expries at: %d | ETA: %s\n",
+			res.QueuedPubkey.PublicKey, res.QueuedPubkey.Expiry, estimator.FormatETA(int64(res.QueuedPubkey.Expiry)-height),
+		)
+	}
+	if plan.Alert {
+		log.Printf("ALERT: Active Pub Key expires in %d blocks (%s) and there is no queued pub key\n", plan.BlocksLeft, estimator.FormatETA(plan.BlocksLeft))
+	}
+
+	if !plan.Generate {
+		if plan.NextCheck > 0 && !plan.HasQueued {
+			log.Printf("Queued Pub Key Not found, generating it at height %d, ETA: %s\n", plan.NextCheck, estimator.FormatETA(plan.NextCheck-height))
 		}
+		return plan.NextCheck, nil
+	}
+
+	log.Println("Queued Pub Key Not found, sending setup request...")
+
+	var validatorsPubInfos []cosmosClient.ValidatorPubInfo
+	err = retry.Do(ctx, "getting verified validators public infos", func() error {
+		var err error
+		validatorsPubInfos, err = sgc.GetVerifiedValidatorsPubInfos(ctx)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	txMsg, _, err := sgc.NewCreateLatestPubkeyMsg(validatorsPubInfos)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = sgc.CosmosClient.UpdateClientAccountInfo(ctx); err != nil {
+		log.Printf("Unable to update client account info: %s", err.Error())
+	}
+
+	// SubmitTx tracks a broadcast tx until it is included or expired for good, only failures
+	// before any tx could land are retried
+	var finalTxResp *tx.GetTxResponse
+	err = retry.Do(ctx, "submitting create latest pubkey tx", func() error {
+		var err error
+		finalTxResp, err = sgc.CosmosClient.SubmitTx(ctx, txMsg, true, time.Second)
+		return err
+	})
+	var inFlight *cosmosClient.InFlightTxError
+	if err != nil && !errors.As(err, &inFlight) {
+		failedShareGenerated.Inc()
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if finalTxResp.TxResponse.Code != 0 {
+		failedShareGenerated.Inc()
+		return 0, Fatal(errors.Errorf("create latest pubkey tx failed: %s", finalTxResp.TxResponse.RawLog))
 	}
+	validShareGenerated.Inc()
+	return 0, nil
 }
@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"ShareGenerationClient/pkg/cosmosClient"
+	"context"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"time"
+)
+
+const (
+	// BlockTimeWindow is the number of recent blocks the block time is averaged over
+	BlockTimeWindow = 100
+	// Until the block time is estimated, durations are converted with a fallback block time picked by each caller
+	// on its safe side. SlowBlockTime converts an interval to fewer blocks so it never elapses late
+	SlowBlockTime = 6 * time.Second
+	// FastBlockTime converts a window before an expiry to more blocks so it never starts late,
+	// CometBFT default timeout_commit is 1s
+	FastBlockTime = time.Second
+)
+
+var blockTimeSeconds = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "sharegenerationclient_block_time_seconds",
+	Help: "The average block time estimated from recent block headers",
+})
+
+// BlockTimeEstimator estimates the average block time from the header time of recent blocks
+type BlockTimeEstimator struct {
+	blocks []Block
+}
+
+// Seed observes the latest block and the one BlockTimeWindow blocks before it,
+// so the block time is known before any new block is received
+func (e *BlockTimeEstimator) Seed(ctx context.Context, cClient *cosmosClient.CosmosClient) error {
+	latest, err := cClient.GetBlockHeader(ctx, 0)
+	if err != nil {
+		return err
+	}
+
+	if latest.Height > BlockTimeWindow {
+		past, err := cClient.GetBlockHeader(ctx, latest.Height-BlockTimeWindow)
+		if err != nil {
+			return err
+		}
+		e.Observe(Block{Height: past.Height, Time: past.Time})
+	}
+
+	e.Observe(Block{Height: latest.Height, Time: latest.Time})
+	return nil
+}
+
+func (e *BlockTimeEstimator) Observe(block Block) {
+	if len(e.blocks) > 0 && block.Height <= e.blocks[len(e.blocks)-1].Height {
+		return
+	}
+
+	e.blocks = append(e.blocks, block)
+
+	// Only keep blocks within the window of the latest one
+	for len(e.blocks) > 2 && block.Height-e.blocks[1].Height >= BlockTimeWindow {
+		e.blocks = e.blocks[1:]
+	}
+
+	blockTimeSeconds.Set(e.Estimate().Seconds())
+}
+
+// Estimate returns the average block time, 0 until two blocks were observed
+func (e *BlockTimeEstimator) Estimate() time.Duration {
+	if len(e.blocks) < 2 {
+		return 0
+	}
+	first, last := e.blocks[0], e.blocks[len(e.blocks)-1]
+	if !last.Time.After(first.Time) {
+		return 0
+	}
+	return last.Time.Sub(first.Time) / time.Duration(last.Height-first.Height)
+}
+
+// blockTimeOr returns the block time, or the fallback while it is unknown
+func blockTimeOr(blockTime, fallback time.Duration) time.Duration {
+	if blockTime <= 0 {
+		return fallback
+	}
+	return blockTime
+}
+
+// BlocksIn returns the number of blocks expected in the given interval, at least 1,
+// with SlowBlockTime while the block time is unknown
+func (e *BlockTimeEstimator) BlocksIn(d time.Duration) int64 {
+	blockTime := blockTimeOr(e.Estimate(), SlowBlockTime)
+	blocks := int64((d + blockTime - 1) / blockTime)
+	if blocks < 1 {
+		return 1
+	}
+	return blocks
+}
+
+// ETA returns the estimated time until the given number of blocks are produced, 0 if unknown
+func (e *BlockTimeEstimator) ETA(blocks int64) time.Duration {
+	return time.Duration(blocks) * e.Estimate()
+}
+
+// FormatETA formats the estimated time until the given number of blocks are produced
+func (e *BlockTimeEstimator) FormatETA(blocks int64) string {
+	if e.Estimate() <= 0 {
+		return "unknown"
+	}
+	eta := e.ETA(blocks).Round(time.Second)
+	return fmt.Sprintf("~%s (%s)", eta, time.Now().Add(eta).Format(time.RFC3339))
+}
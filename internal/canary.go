@@ -0,0 +1,279 @@
+package internal
+
+import (
+	"ShareGenerationClient/config"
+	"ShareGenerationClient/pkg/cosmosClient"
+	"context"
+	"cosmossdk.io/math"
+	peptypes "github.com/Fairblock/fairyring/x/pep/types"
+	abcitypes "github.com/cometbft/cometbft/abci/types"
+	tmclient "github.com/cometbft/cometbft/rpc/client/http"
+	cosmostypes "github.com/cosmos/cosmos-sdk/types"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	CanaryExecuted  = "executed"
+	CanaryReverted  = "reverted"
+	CanaryDiscarded = "discarded"
+	CanaryMissing   = "missing"
+	CanaryError     = "error"
+)
+
+var (
+	canaryRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sharegenerationclient_canary_runs",
+		Help: "The total number of encryption canaries by result: executed, reverted, discarded, missing or error",
+	}, []string{"result"})
+
+	canaryFailing = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "sharegenerationclient_canary_failing",
+		Help: "1 if the latest encryption canary was not executed, 0 otherwise",
+	})
+
+	canaryExecutionDelay = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "sharegenerationclient_canary_execution_delay_blocks",
+		Help: "The number of blocks between the latest executed canary target height and its execution",
+	})
+
+	canaryLastExecutedHeight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "sharegenerationclient_canary_last_executed_height",
+		Help: "The target height of the latest executed encryption canary",
+	})
+)
+
+// CanaryResult is what happened to an encrypted canary tx after its target height
+type CanaryResult struct {
+	Status         string
+	TargetHeight   uint64
+	Index          uint64
+	ExecutedHeight int64
+	Reason         string
+}
+
+// EncryptionCanary periodically submits an encrypted tx to the pep module and checks it is decrypted & executed,
+// so a broken encryption pipeline is noticed before users do
+type EncryptionCanary struct {
+	cosmosClient *cosmosClient.CosmosClient
+	rpcClient    *tmclient.HTTP
+	denom        string
+	interval     time.Duration
+	targetBlocks uint64
+	verifyBlocks uint64
+	pollInterval time.Duration
+}
+
+func NewEncryptionCanary(cfg *config.Config, cClient *cosmosClient.CosmosClient) (*EncryptionCanary, error) {
+	rpcClient, err := tmclient.New(cfg.GetFairyRingNodeURI(), "/websocket")
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating rpc client")
+	}
+
+	c := EncryptionCanary{
+		cosmosClient: cClient,
+		rpcClient:    rpcClient,
+		denom:        cfg.FairyRingNode.Denom,
+		interval:     cfg.Canary.Interval,
+		targetBlocks: cfg.Canary.TargetBlocks,
+		verifyBlocks: cfg.Canary.VerifyBlocks,
+		pollInterval: cfg.Websocket.PollInterval,
+	}
+	if c.interval <= 0 {
+		c.interval = config.DefaultCanaryInterval
+	}
+	if c.targetBlocks == 0 {
+		c.targetBlocks = config.DefaultCanaryTargetBlocks
+	}
+	if c.verifyBlocks == 0 {
+		c.verifyBlocks = config.DefaultCanaryVerifyBlocks
+	}
+	if c.pollInterval <= 0 {
+		c.pollInterval = config.DefaultWebsocketPollInterval
+	}
+	return &c, nil
+}
+
+// Run submits a canary right away & every interval until ctx is done
+func (c *EncryptionCanary) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		result, err := c.RunOnce(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		recordCanaryResult(result, err)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// RunOnce submits an encrypted canary tx for a few blocks ahead and waits until it is executed, reverted or discarded
+func (c *EncryptionCanary) RunOnce(ctx context.Context) (*CanaryResult, error) {
+	height, err := c.cosmosClient.GetChainHeight(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting chain height")
+	}
+
+	res, err := c.cosmosClient.GetActivePubKey(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting pub keys")
+	}
+	if len(res.ActivePubkey.PublicKey) == 0 {
+		return nil, errors.New("no active pub key to encrypt the canary to")
+	}
+
+	// Target height has to be covered by the pub key the canary is encrypted to
+	target := uint64(height) + c.targetBlocks
+	pubKey := res.ActivePubkey.PublicKey
+	if target > res.ActivePubkey.Expiry {
+		if len(res.QueuedPubkey.PublicKey) > 0 {
+			pubKey = res.QueuedPubkey.PublicKey
+		} else {
+			target = res.ActivePubkey.Expiry
+		}
+	}
+	if target <= uint64(height) {
+		return nil, errors.Errorf("active pub key expires at %d without any queued pub key", res.ActivePubkey.Expiry)
+	}
+
+	pepNonce, err := c.cosmosClient.GetPepNonce(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting pep nonce")
+	}
+
+	address := c.cosmosClient.GetAccAddress()
+	payload := banktypes.NewMsgSend(address, address, cosmostypes.NewCoins(cosmostypes.NewCoin(c.denom, math.OneInt())))
+
+	data, err := c.cosmosClient.EncryptTx(ctx, payload, pepNonce, pubKey, target)
+	if err != nil {
+		return nil, err
+	}
+
+	txResp, err := c.cosmosClient.SubmitTx(ctx, &peptypes.MsgSubmitEncryptedTx{
+		Creator:           c.cosmosClient.GetAddress(),
+		Data:              data,
+		TargetBlockHeight: target,
+	}, true, time.Second)
+	if err != nil {
+		return nil, errors.Wrap(err, "error submitting encrypted tx")
+	}
+	if txResp.TxResponse.Code != 0 {
+		return nil, errors.Errorf("submit encrypted tx failed: %s", txResp.TxResponse.RawLog)
+	}
+
+	index, found := cosmosClient.EncryptedTxIndex(txResp.TxResponse.Events)
+	if !found {
+		return nil, errors.New("encrypted tx index not found in submit encrypted tx events")
+	}
+
+	log.Printf("Encryption canary submitted for height %d, index: %d\n", target, index)
+	return c.waitForExecution(ctx, target, index)
+}
+
+// waitForExecution looks for the canary outcome in the block events from the target height
+// up to the verify blocks after it
+func (c *EncryptionCanary) waitForExecution(ctx context.Context, target, index uint64) (*CanaryResult, error) {
+	result := CanaryResult{Status: CanaryMissing, TargetHeight: target, Index: index}
+	creator := c.cosmosClient.GetAddress()
+
+	for h := int64(target); h <= int64(target+c.verifyBlocks); h++ {
+		if err := c.waitForHeight(ctx, h); err != nil {
+			return nil, err
+		}
+
+		blockResults, err := c.rpcClient.BlockResults(ctx, &h)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error getting block %d results", h)
+		}
+
+		for _, e := range blockResults.FinalizeBlockEvents {
+			attrs := eventAttributes(e)
+			switch e.Type {
+			case peptypes.EncryptedTxExecutedEventType:
+				if attrs[peptypes.EncryptedTxExecutedEventCreator] == creator && attrs[peptypes.EncryptedTxExecutedEventIndex] == strconv.FormatUint(index, 10) {
+					result.Status = CanaryExecuted
+					result.ExecutedHeight = h
+					return &result, nil
+				}
+			case peptypes.EncryptedTxRevertedEventType:
+				if attrs[peptypes.EncryptedTxRevertedEventCreator] == creator && attrs[peptypes.EncryptedTxRevertedEventIndex] == strconv.FormatUint(index, 10) {
+					result.Status = CanaryReverted
+					result.ExecutedHeight = h
+					result.Reason = attrs[peptypes.EncryptedTxRevertedEventReason]
+					return &result, nil
+				}
+			case peptypes.EncryptedTxDiscardedEventType:
+				ids := strings.Split(attrs[peptypes.EncryptedTxDiscardedEventTxIDs], ",")
+				if attrs[peptypes.EncryptedTxDiscardedEventHeight] == strconv.FormatUint(target, 10) && slices.Contains(ids, strconv.FormatUint(index, 10)) {
+					result.Status = CanaryDiscarded
+					result.ExecutedHeight = h
+					result.Reason = "decryption key not found for the target height"
+					return &result, nil
+				}
+			}
+		}
+	}
+
+	result.Reason = "no execution within " + strconv.FormatUint(c.verifyBlocks, 10) + " blocks after the target height"
+	return &result, nil
+}
+
+func (c *EncryptionCanary) waitForHeight(ctx context.Context, height int64) error {
+	for {
+		latest, err := c.cosmosClient.GetChainHeight(ctx)
+		if err != nil {
+			log.Printf("Unable to get chain height while waiting for the canary: %s\n", err.Error())
+		} else if latest >= height {
+			return nil
+		}
+
+		select {
+		case <-time.After(c.pollInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func eventAttributes(e abcitypes.Event) map[string]string {
+	attrs := make(map[string]string, len(e.Attributes))
+	for _, attr := range e.Attributes {
+		attrs[attr.Key] = attr.Value
+	}
+	return attrs
+}
+
+func recordCanaryResult(result *CanaryResult, err error) {
+	if err != nil {
+		canaryRuns.WithLabelValues(CanaryError).Inc()
+		canaryFailing.Set(1)
+		log.Printf("ALERT: Encryption canary failed: %s\n", err.Error())
+		return
+	}
+
+	canaryRuns.WithLabelValues(result.Status).Inc()
+	if result.Status != CanaryExecuted {
+		canaryFailing.Set(1)
+		log.Printf("ALERT: Encryption canary for height %d %s: %s\n", result.TargetHeight, result.Status, result.Reason)
+		return
+	}
+
+	canaryFailing.Set(0)
+	canaryExecutionDelay.Set(float64(result.ExecutedHeight - int64(result.TargetHeight)))
+	canaryLastExecutedHeight.Set(float64(result.TargetHeight))
+	log.Printf("Encryption canary for height %d executed at height %d\n", result.TargetHeight, result.ExecutedHeight)
+}
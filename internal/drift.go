@@ -0,0 +1,191 @@
+package internal
+
+import (
+	"ShareGenerationClient/config"
+	"ShareGenerationClient/pkg/cosmosClient"
+	"context"
+	"fmt"
+	"github.com/cosmos/cosmos-sdk/types/tx"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"log"
+	"time"
+)
+
+const (
+	AutoOverrideSucceeded = "succeeded"
+	AutoOverrideFailed    = "failed"
+)
+
+var (
+	validatorSetOverlap = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "sharegenerationclient_validator_set_overlap",
+		Help: "The fraction of the active pub key holders & the keyshare module validator set in common",
+	})
+
+	activePubkeyRemainingHolders = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "sharegenerationclient_active_pubkey_remaining_holders",
+		Help: "The number of active pub key holders still in the keyshare module validator set",
+	})
+
+	autoOverrides = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sharegenerationclient_auto_overrides",
+		Help: "The total number of active pub key overrides submitted because the validator set drifted",
+	}, []string{"result"})
+)
+
+// OverridePolicy decides when the active pub key is overridden because the validator set drifted from its holders
+type OverridePolicy struct {
+	Enabled    bool
+	MinOverlap float64
+}
+
+// ValidatorSetDrift compares the holders of the active pub key shares with the keyshare module validator set.
+// Overlap is the number of addresses in both over the number of addresses in either,
+// so validators joining lower it as much as validators leaving
+type ValidatorSetDrift struct {
+	Holders   int
+	Remaining int
+	Threshold int
+	Overlap   float64
+	Joined    []string
+	Left      []string
+}
+
+func NewOverridePolicy(cfg *config.Config) OverridePolicy {
+	return OverridePolicy{
+		Enabled:    cfg.AutoOverride.Enabled,
+		MinOverlap: cfg.AutoOverride.MinOverlap,
+	}
+}
+
+// shareHolder returns the address the active pub key share was encrypted for, the authorized address if any
+func shareHolder(info cosmosClient.ValidatorPubInfo) string {
+	if len(info.Authorizing) > 0 {
+		return info.Authorizing
+	}
+	return info.Address
+}
+
+// CompareValidatorSets returns the drift between the active pub key holders and the validator set,
+// the threshold is the one of the number of validators the active pub key was generated for
+func CompareValidatorSets(holders, validators []cosmosClient.ValidatorPubInfo, numberOfValidators uint64) ValidatorSetDrift {
+	d := ValidatorSetDrift{
+		Holders:   int(numberOfValidators),
+		Threshold: Threshold(int(numberOfValidators)),
+		Joined:    make([]string, 0),
+		Left:      make([]string, 0),
+	}
+
+	current := make(map[string]bool, len(validators))
+	for _, v := range validators {
+		current[v.Address] = true
+	}
+
+	held := make(map[string]bool, len(holders))
+	for _, h := range holders {
+		addr := shareHolder(h)
+		held[addr] = true
+		if current[addr] {
+			d.Remaining++
+		} else {
+			d.Left = append(d.Left, addr)
+		}
+	}
+
+	for _, v := range validators {
+		if !held[v.Address] {
+			d.Joined = append(d.Joined, v.Address)
+		}
+	}
+
+	if union := d.Remaining + len(d.Left) + len(d.Joined); union > 0 {
+		d.Overlap = float64(d.Remaining) / float64(union)
+	}
+	return d
+}
+
+// ShouldOverride returns why the active pub key has to be overridden, empty if it does not
+func (p OverridePolicy) ShouldOverride(d ValidatorSetDrift) string {
+	if !p.Enabled || d.Holders == 0 {
+		return ""
+	}
+	if d.Remaining < d.Threshold {
+		return fmt.Sprintf("%d key holders left in the validator set, under the threshold of %d", d.Remaining, d.Threshold)
+	}
+	if d.Overlap < p.MinOverlap {
+		return fmt.Sprintf("validator set overlap %.2f is under %.2f", d.Overlap, p.MinOverlap)
+	}
+	return ""
+}
+
+// checkValidatorSetDrift overrides the active pub key with one generated for the current validator set
+// when the override policy says so
+func (sgc *ShareGeneratorClient) checkValidatorSetDrift(ctx context.Context, retry *retrier) error {
+	var holders, validators []cosmosClient.ValidatorPubInfo
+	var numberOfValidators uint64
+	err := retry.Do(ctx, "getting active pub key validators info", func() error {
+		var err error
+		holders, numberOfValidators, err = sgc.CosmosClient.GetCurrentPubKeyHolders(ctx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if len(holders) == 0 {
+		return nil
+	}
+
+	err = retry.Do(ctx, "getting verified validators public infos", func() error {
+		var err error
+		validators, err = sgc.GetVerifiedValidatorsPubInfos(ctx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	drift := CompareValidatorSets(holders, validators, numberOfValidators)
+	validatorSetOverlap.Set(drift.Overlap)
+	activePubkeyRemainingHolders.Set(float64(drift.Remaining))
+
+	reason := sgc.OverridePolicy.ShouldOverride(drift)
+	if len(reason) == 0 {
+		return nil
+	}
+
+	log.Printf(
+		"Validator set drifted from the active pub key holders: %s | Joined: %v | Left: %v, overriding the active pub key...\n",
+		reason, drift.Joined, drift.Left,
+	)
+
+	txMsg, _, err := sgc.NewOverrideLatestPubkeyMsg(validators)
+	if err != nil {
+		return err
+	}
+
+	// SubmitTx tracks a broadcast tx until it is included or expired for good, only failures
+	// before any tx could land are retried
+	var txResp *tx.GetTxResponse
+	err = retry.Do(ctx, "submitting override latest pubkey tx", func() error {
+		var err error
+		txResp, err = sgc.CosmosClient.SubmitTx(ctx, txMsg, true, time.Second)
+		return err
+	})
+	var inFlight *cosmosClient.InFlightTxError
+	if err != nil && !errors.As(err, &inFlight) {
+		autoOverrides.WithLabelValues(AutoOverrideFailed).Inc()
+	}
+	if err != nil {
+		return err
+	}
+
+	if txResp.TxResponse.Code != 0 {
+		autoOverrides.WithLabelValues(AutoOverrideFailed).Inc()
+		return Fatal(errors.Errorf("override latest pubkey tx failed: %s", txResp.TxResponse.RawLog))
+	}
+	autoOverrides.WithLabelValues(AutoOverrideSucceeded).Inc()
+	log.Printf("Active pub key overridden for %d validators\n", len(validators))
+	return nil
+}
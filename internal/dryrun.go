@@ -0,0 +1,90 @@
+package internal
+
+import (
+	"ShareGenerationClient/config"
+	"context"
+	"fmt"
+	cosmostypes "github.com/cosmos/cosmos-sdk/types"
+	"github.com/pkg/errors"
+	"log"
+	"strings"
+)
+
+// DryRun runs the pub key check pipeline once, up to the gas simulation, without broadcasting anything.
+// The queued pub key is generated even if it is not due yet, and the process exit code is returned
+func DryRun(ctx context.Context, cfg *config.Config) int {
+	masterClient, err := NewShareGeneratorClient(ctx, cfg)
+	if err != nil {
+		log.Printf("Unable to create client: %s\n", err.Error())
+		return ExitCodeError
+	}
+	defer masterClient.Close()
+
+	var estimator BlockTimeEstimator
+	if err = estimator.Seed(ctx, masterClient.CosmosClient); err != nil {
+		log.Printf("Unable to estimate block time from recent blocks: %s\n", err.Error())
+	}
+
+	height, err := masterClient.CosmosClient.GetChainHeight(ctx)
+	if err != nil {
+		log.Printf("Unable to get chain height: %s\n", err.Error())
+		return ExitCodeError
+	}
+
+	res, err := masterClient.CosmosClient.GetActivePubKey(ctx)
+	if err != nil && !strings.Contains(err.Error(), "Active Public Key does not exists") {
+		log.Printf("Unable to get pub keys: %s\n", err.Error())
+		return ExitCodeError
+	}
+
+	plan := masterClient.Schedule.Plan(height, res, estimator.Estimate())
+	switch {
+	case plan.Generate:
+		log.Printf("Latest Block Height: %d | Queued Pub Key is due, generating it...\n", height)
+	case plan.HasQueued:
+		log.Printf("Latest Block Height: %d | Queued Pub Key already exists, rehearsing its generation...\n", height)
+	default:
+		log.Printf(
+			"Latest Block Height: %d | Queued Pub Key is due at height %d, ETA: %s, rehearsing its generation...\n",
+			height, plan.NextCheck, estimator.FormatETA(plan.NextCheck-height),
+		)
+	}
+
+	validatorsPubInfos, err := masterClient.GetVerifiedValidatorsPubInfos(ctx)
+	if err != nil {
+		log.Printf("Unable to get verified validators public infos: %s\n", err.Error())
+		return ExitCodeError
+	}
+
+	txMsg, generatedResult, err := masterClient.NewCreateLatestPubkeyMsg(validatorsPubInfos)
+	if err != nil {
+		log.Printf("Unable to create latest pubkey msg: %s\n", err.Error())
+		return ExitCodeError
+	}
+
+	if err = masterClient.ReportDryRun(ctx, txMsg, generatedResult); err != nil {
+		log.Printf("Dry run failed: %s\n", err.Error())
+		return ExitCodeError
+	}
+	return ExitCodeOK
+}
+
+// ReportDryRun simulates the msg and prints what would be broadcast with the estimated fee
+func (sgc *ShareGeneratorClient) ReportDryRun(ctx context.Context, msg cosmostypes.Msg, generatedResult *GenerateResult) error {
+	estimate, err := sgc.CosmosClient.EstimateTx(ctx, msg)
+	if err != nil {
+		return errors.Wrap(err, "error estimating tx fee")
+	}
+
+	n := len(generatedResult.EncryptedKeyShares)
+	fmt.Println("================")
+	fmt.Println("Dry run, nothing is broadcast")
+	fmt.Printf("Msg: %s | Creator: %s\n", cosmostypes.MsgTypeURL(msg), sgc.CosmosClient.GetAddress())
+	fmt.Printf("Pub Key: %s\n", generatedResult.MasterPublicKey)
+	fmt.Printf("Validators: %d | Threshold: %d\n", n, Threshold(n))
+	for i, share := range generatedResult.EncryptedKeyShares {
+		fmt.Printf("[%d] %s\n", i+1, share.ValidatorAddress)
+	}
+	fmt.Printf("Estimated Gas: %d | Gas Price: %s | Fee: %s\n", estimate.GasLimit, estimate.GasPrice, estimate.Fee)
+	return nil
+}
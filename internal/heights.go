@@ -0,0 +1,221 @@
+package internal
+
+import (
+	"ShareGenerationClient/config"
+	"ShareGenerationClient/pkg/cosmosClient"
+	"context"
+	tmclient "github.com/cometbft/cometbft/rpc/client/http"
+	tmtypes "github.com/cometbft/cometbft/types"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"log"
+	"time"
+)
+
+const newBlockHeaderQuery = "tm.event = 'NewBlockHeader'"
+
+var (
+	websocketSubscribed = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "sharegenerationclient_websocket_subscribed",
+		Help: "1 if block heights are received over the websocket, 0 if they are polled",
+	})
+
+	websocketResubscriptions = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "sharegenerationclient_websocket_resubscriptions",
+		Help: "The total number of times the block header subscription was closed, stalled or failed and retried",
+	})
+)
+
+// Block is the height & header time of a new block
+type Block struct {
+	Height int64
+	Time   time.Time
+}
+
+// HeightWatcher delivers new block heights from the websocket block header subscription,
+// and polls the latest height from the node while the subscription is down
+type HeightWatcher struct {
+	rpcEndpoint  string
+	cosmosClient *cosmosClient.CosmosClient
+	pollInterval time.Duration
+	stallTimeout time.Duration
+	backoff      RetryPolicy
+	lastHeight   int64
+	triggers     chan<- CheckTrigger
+	dial         func() (eventClient, error)
+}
+
+// eventClient is the websocket client heights, triggers & tx events are subscribed with
+type eventClient interface {
+	cosmosClient.EventSubscriber
+	Start() error
+	Stop() error
+}
+
+func NewHeightWatcher(cfg *config.Config, cClient *cosmosClient.CosmosClient) *HeightWatcher {
+	w := HeightWatcher{
+		rpcEndpoint:  cfg.GetFairyRingNodeURI(),
+		cosmosClient: cClient,
+		pollInterval: cfg.Websocket.PollInterval,
+		stallTimeout: cfg.Websocket.StallTimeout,
+		backoff:      NewRetryPolicy(cfg),
+	}
+	if w.pollInterval <= 0 {
+		w.pollInterval = config.DefaultWebsocketPollInterval
+	}
+	if w.stallTimeout <= 0 {
+		w.stallTimeout = config.DefaultWebsocketStallTimeout
+	}
+	w.dial = w.dialWebsocket
+	return &w
+}
+
+// dialWebsocket creates a new websocket client, every client has its own node subscription slots
+func (w *HeightWatcher) dialWebsocket() (eventClient, error) {
+	client, err := tmclient.New(w.rpcEndpoint, "/websocket")
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// SetTriggers makes the watcher also subscribe to the keyshare module events triggering a pub key check,
+// events are dropped while the channel is full
+func (w *HeightWatcher) SetTriggers(triggers chan<- CheckTrigger) {
+	w.triggers = triggers
+}
+
+// Run sends every new block height to the channel, heights are dropped while the channel is full.
+// The subscription is retried with backoff whenever it is down, the channel is closed once ctx is done
+func (w *HeightWatcher) Run(ctx context.Context, heights chan<- Block) {
+	defer close(heights)
+
+	var failures uint64
+	for {
+		delivered, err := w.watchSubscription(ctx, heights)
+		websocketSubscribed.Set(0)
+		if ctx.Err() != nil {
+			return
+		}
+		websocketResubscriptions.Inc()
+
+		if delivered {
+			failures = 0
+		}
+		delay := w.backoff.Backoff(failures)
+		failures++
+
+		log.Printf(
+			"Block header subscription down: %s, polling latest height every %s, resubscribing in %s\n",
+			err.Error(), w.pollInterval, delay,
+		)
+		w.poll(ctx, heights, delay)
+	}
+}
+
+// watchSubscription delivers heights from a new websocket subscription until it is closed or stalled,
+// delivered is true if at least one height was received
+func (w *HeightWatcher) watchSubscription(ctx context.Context, heights chan<- Block) (delivered bool, err error) {
+	client, err := w.dial()
+	if err != nil {
+		return false, errors.Wrap(err, "error creating rpc client")
+	}
+	if err = client.Start(); err != nil {
+		return false, errors.Wrap(err, "error starting websocket client")
+	}
+	defer func() {
+		w.cosmosClient.ClearEventClient()
+		if err := client.Stop(); err != nil {
+			log.Printf("Unable to stop websocket client: %s\n", err.Error())
+		}
+	}()
+
+	subscribeCtx, cancel := context.WithTimeout(ctx, cosmosClient.SubscribeTimeout)
+	out, err := client.Subscribe(subscribeCtx, "", newBlockHeaderQuery)
+	cancel()
+	if err != nil {
+		return false, errors.Wrap(err, "error subscribing to block headers")
+	}
+
+	websocketSubscribed.Set(1)
+	log.Println("Subscribed to block headers")
+
+	if err = w.cosmosClient.SetEventClient(ctx, client); err != nil {
+		log.Printf("ALERT: Tx event subscription rejected, polling submitted txs instead: %s\n", err.Error())
+	}
+
+	if w.triggers != nil {
+		triggersCtx, cancelTriggers := context.WithCancel(ctx)
+		defer cancelTriggers()
+		subscribeTriggers(triggersCtx, w.dial, w.triggers)
+	}
+
+	stall := time.NewTimer(w.stallTimeout)
+	defer stall.Stop()
+
+	for {
+		select {
+		case result, ok := <-out:
+			if !ok {
+				return delivered, errors.New("subscription closed")
+			}
+			newBlockHeader, ok := result.Data.(tmtypes.EventDataNewBlockHeader)
+			if !ok {
+				continue
+			}
+			delivered = true
+			w.deliver(heights, Block{Height: newBlockHeader.Header.Height, Time: newBlockHeader.Header.Time})
+
+			if !stall.Stop() {
+				select {
+				case <-stall.C:
+				default:
+				}
+			}
+			stall.Reset(w.stallTimeout)
+		case <-ctx.Done():
+			return delivered, ctx.Err()
+		case <-stall.C:
+			return delivered, errors.Errorf("no block header received for %s", w.stallTimeout)
+		}
+	}
+}
+
+// poll delivers the latest height every poll interval for the given duration
+func (w *HeightWatcher) poll(ctx context.Context, heights chan<- Block, duration time.Duration) {
+	deadline := time.NewTimer(duration)
+	defer deadline.Stop()
+
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		header, err := w.cosmosClient.GetBlockHeader(ctx, 0)
+		if err != nil {
+			log.Printf("Unable to poll latest height: %s\n", err.Error())
+		} else {
+			w.deliver(heights, Block{Height: header.Height, Time: header.Time})
+		}
+
+		select {
+		case <-ticker.C:
+		case <-deadline.C:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (w *HeightWatcher) deliver(heights chan<- Block, block Block) {
+	if block.Height <= w.lastHeight {
+		return
+	}
+	w.lastHeight = block.Height
+
+	select {
+	case heights <- block:
+	default:
+	}
+}
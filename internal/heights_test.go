@@ -0,0 +1,227 @@
+package internal
+
+import (
+	"ShareGenerationClient/pkg/cosmosClient"
+	"context"
+	"encoding/hex"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	keysharetypes "github.com/Fairblock/fairyring/x/keyshare/types"
+	abcitypes "github.com/cometbft/cometbft/abci/types"
+	coretypes "github.com/cometbft/cometbft/rpc/core/types"
+	tmtypes "github.com/cometbft/cometbft/types"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	"github.com/cosmos/cosmos-sdk/crypto/keys/secp256k1"
+	cosmostypes "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/tx"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxSubscriptionsPerClient is the CometBFT default rpc max_subscriptions_per_client
+const maxSubscriptionsPerClient = 5
+
+// fakeNode hands out websocket clients rejecting subscriptions over the node limit, like CometBFT does
+type fakeNode struct {
+	mu       sync.Mutex
+	clients  []*fakeEventClient
+	rejected []string
+}
+
+func (n *fakeNode) dial() (eventClient, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	client := &fakeEventClient{node: n, subscriptions: make(map[string]chan coretypes.ResultEvent)}
+	n.clients = append(n.clients, client)
+	return client, nil
+}
+
+// publish sends the event to every subscription whose query contains the given part, returning the subscription count
+func (n *fakeNode) publish(queryPart string, event coretypes.ResultEvent) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	published := 0
+	for _, client := range n.clients {
+		for query, out := range client.subscriptions {
+			if !strings.Contains(query, queryPart) {
+				continue
+			}
+			select {
+			case out <- event:
+			default:
+			}
+			published++
+		}
+	}
+	return published
+}
+
+type fakeEventClient struct {
+	node          *fakeNode
+	subscriptions map[string]chan coretypes.ResultEvent
+}
+
+func (c *fakeEventClient) Subscribe(_ context.Context, _, query string, _ ...int) (<-chan coretypes.ResultEvent, error) {
+	c.node.mu.Lock()
+	defer c.node.mu.Unlock()
+	if len(c.subscriptions) >= maxSubscriptionsPerClient {
+		c.node.rejected = append(c.node.rejected, query)
+		return nil, errors.Errorf("max_subscriptions_per_client %d reached", maxSubscriptionsPerClient)
+	}
+	out := make(chan coretypes.ResultEvent, 1)
+	c.subscriptions[query] = out
+	return out, nil
+}
+
+func (c *fakeEventClient) Start() error { return nil }
+
+func (c *fakeEventClient) Stop() error { return nil }
+
+// fakeGRPCNode serves the account of the client & never finds any tx, so txs are only confirmed by event
+type fakeGRPCNode struct {
+	authtypes.UnimplementedQueryServer
+	tx.UnimplementedServiceServer
+	account *codectypes.Any
+}
+
+func (n *fakeGRPCNode) Account(context.Context, *authtypes.QueryAccountRequest) (*authtypes.QueryAccountResponse, error) {
+	return &authtypes.QueryAccountResponse{Account: n.account}, nil
+}
+
+func (n *fakeGRPCNode) GetTx(context.Context, *tx.GetTxRequest) (*tx.GetTxResponse, error) {
+	return nil, status.Error(codes.NotFound, "tx not found")
+}
+
+func newTestCosmosClient(t *testing.T, ctx context.Context) *cosmosClient.CosmosClient {
+	t.Helper()
+	privateKey := secp256k1.GenPrivKey()
+	account, err := codectypes.NewAnyWithValue(
+		authtypes.NewBaseAccount(cosmostypes.AccAddress(privateKey.PubKey().Address()), nil, 3, 7),
+	)
+	if err != nil {
+		t.Fatalf("error packing account: %s", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %s", err)
+	}
+	server := grpc.NewServer()
+	node := &fakeGRPCNode{account: account}
+	authtypes.RegisterQueryServer(server, node)
+	tx.RegisterServiceServer(server, node)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	c, err := cosmosClient.NewCosmosClient(ctx, listener.Addr().String(), hex.EncodeToString(privateKey.Key), "fairyring-test")
+	if err != nil {
+		t.Fatalf("error creating cosmos client: %s", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestWatchSubscriptionWithTriggers(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	c := newTestCosmosClient(t, ctx)
+	node := &fakeNode{}
+	triggers := make(chan CheckTrigger, 1)
+	heights := make(chan Block, 1)
+
+	w := HeightWatcher{
+		cosmosClient: c,
+		pollInterval: time.Second,
+		stallTimeout: time.Minute,
+		triggers:     triggers,
+		dial:         node.dial,
+	}
+	go w.watchSubscription(ctx, heights)
+
+	// Block headers
+	for node.publish("NewBlockHeader", coretypes.ResultEvent{
+		Data: tmtypes.EventDataNewBlockHeader{Header: tmtypes.Header{Height: 10, Time: time.Now()}},
+	}) == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case block := <-heights:
+		if block.Height != 10 {
+			t.Fatalf("block height = %d, want 10", block.Height)
+		}
+	case <-ctx.Done():
+		t.Fatal("no block height delivered")
+	}
+
+	// Triggers
+	triggerEvent := coretypes.ResultEvent{
+		Data: tmtypes.EventDataTx{TxResult: abcitypes.TxResult{Height: 11}},
+		Events: map[string][]string{
+			"message.action": {cosmostypes.MsgTypeURL(&keysharetypes.MsgOverrideLatestPubkey{})},
+		},
+	}
+	for node.publish("LatestPubkey", triggerEvent) == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case trigger := <-triggers:
+		if trigger.Event != TriggerPubkeyOverrode || trigger.Height != 11 {
+			t.Fatalf("trigger = %+v, want %s at height 11", trigger, TriggerPubkeyOverrode)
+		}
+	case <-ctx.Done():
+		t.Fatal("no trigger delivered")
+	}
+
+	// Tx confirmation, the node never finds the tx so it can only be confirmed by its event
+	txBytes := tmtypes.Tx("signed tx")
+	hash := strings.ToUpper(hex.EncodeToString(txBytes.Hash()))
+	txEvent := coretypes.ResultEvent{
+		Data: tmtypes.EventDataTx{TxResult: abcitypes.TxResult{Height: 12, Tx: txBytes}},
+	}
+
+	confirmed := make(chan *tx.GetTxResponse, 1)
+	waitCtx, cancelWait := context.WithTimeout(ctx, 5*time.Second)
+	defer cancelWait()
+	go func() {
+		resp, err := c.WaitForTx(waitCtx, hash, 0, 10*time.Millisecond)
+		if err != nil {
+			t.Errorf("WaitForTx() error = %v", err)
+		}
+		confirmed <- resp
+	}()
+
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for resp := (*tx.GetTxResponse)(nil); resp == nil; {
+		select {
+		case resp = <-confirmed:
+			if resp == nil {
+				t.FailNow()
+			}
+			if resp.TxResponse.Height != 12 {
+				t.Fatalf("tx height = %d, want 12", resp.TxResponse.Height)
+			}
+		case <-ticker.C:
+			node.publish("tx.acc_seq", txEvent)
+		}
+	}
+
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	if len(node.rejected) > 0 {
+		t.Fatalf("subscriptions rejected over the node limit: %v", node.rejected)
+	}
+	for i, client := range node.clients {
+		if len(client.subscriptions) > maxSubscriptionsPerClient {
+			t.Fatalf("client %d has %d subscriptions", i, len(client.subscriptions))
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package internal
+
+import (
+	"ShareGenerationClient/config"
+	"ShareGenerationClient/pkg/cosmosClient"
+	"context"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"log"
+	"strings"
+)
+
+const (
+	DecryptionKeyAggregated = "aggregated"
+	DecryptionKeyMissing    = "missing"
+)
+
+var (
+	decryptionKeys = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sharegenerationclient_decryption_keys",
+		Help: "The total number of heights by decryption key result: aggregated or missing",
+	}, []string{"result"})
+
+	decryptionKeyLag = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "sharegenerationclient_decryption_key_lag_blocks",
+		Help: "The number of blocks after its height the latest decryption key was found aggregated",
+	})
+
+	decryptionKeyLatestHeight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "sharegenerationclient_decryption_key_latest_height",
+		Help: "The latest height a decryption key was found aggregated for",
+	})
+
+	decryptionKeysMissingConsecutive = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "sharegenerationclient_decryption_keys_missing_consecutive",
+		Help: "The number of consecutive heights without any aggregated decryption key",
+	})
+
+	decryptionKeyAlert = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "sharegenerationclient_decryption_key_alert",
+		Help: "1 if decryption keys stopped being aggregated, 0 otherwise",
+	})
+)
+
+// KeyAvailabilityMonitor checks the decryption key of every height is aggregated within the max lag,
+// keys missing for consecutive heights usually mean the generated shares are not used correctly
+type KeyAvailabilityMonitor struct {
+	cosmosClient *cosmosClient.CosmosClient
+	maxLag       int64
+	alertAfter   uint64
+
+	pending    []int64
+	lastHeight int64
+	missing    uint64
+}
+
+func NewKeyAvailabilityMonitor(cfg *config.Config, cClient *cosmosClient.CosmosClient) *KeyAvailabilityMonitor {
+	m := KeyAvailabilityMonitor{
+		cosmosClient: cClient,
+		maxLag:       int64(cfg.KeyMonitor.MaxLagBlocks),
+		alertAfter:   cfg.KeyMonitor.AlertAfterMissing,
+	}
+	if m.maxLag <= 0 {
+		m.maxLag = config.DefaultKeyMonitorMaxLagBlocks
+	}
+	if m.alertAfter == 0 {
+		m.alertAfter = config.DefaultKeyMonitorAlertAfterMissing
+	}
+	return &m
+}
+
+// Run observes the decryption keys on every new block until ctx is done
+func (m *KeyAvailabilityMonitor) Run(ctx context.Context, blocks <-chan Block) {
+	for {
+		select {
+		case block := <-blocks:
+			if err := m.Observe(ctx, block.Height); err != nil && ctx.Err() == nil {
+				log.Printf("Unable to observe decryption keys at height %d: %s\n", block.Height, err.Error())
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Observe starts waiting for the keys of the heights up to the given one,
+// and checks the keys waited for until they are aggregated or lag more than the max lag
+func (m *KeyAvailabilityMonitor) Observe(ctx context.Context, height int64) error {
+	if height > m.lastHeight {
+		from := max(m.lastHeight+1, height-m.maxLag)
+		if m.lastHeight == 0 {
+			from = height
+		}
+		for h := from; h <= height; h++ {
+			m.pending = append(m.pending, h)
+		}
+		m.lastHeight = height
+	}
+
+	remaining := make([]int64, 0, len(m.pending))
+	for i, h := range m.pending {
+		_, found, err := m.cosmosClient.GetDecryptionKey(ctx, uint64(h))
+		if err != nil {
+			m.pending = append(remaining, m.pending[i:]...)
+			return errors.Wrapf(err, "error getting decryption key for height %d", h)
+		}
+
+		switch {
+		case found:
+			m.aggregated(h, height-h)
+		case height-h >= m.maxLag:
+			if err = m.keyMissing(ctx, h); err != nil {
+				m.pending = append(remaining, m.pending[i:]...)
+				return err
+			}
+		default:
+			remaining = append(remaining, h)
+		}
+	}
+	m.pending = remaining
+	return nil
+}
+
+func (m *KeyAvailabilityMonitor) aggregated(height, lag int64) {
+	decryptionKeys.WithLabelValues(DecryptionKeyAggregated).Inc()
+	decryptionKeyLag.Set(float64(lag))
+	decryptionKeyLatestHeight.Set(float64(height))
+
+	if m.missing >= m.alertAfter {
+		log.Printf("Decryption keys aggregated again from height %d after %d missing\n", height, m.missing)
+	}
+	m.missing = 0
+	decryptionKeysMissingConsecutive.Set(0)
+	decryptionKeyAlert.Set(0)
+}
+
+// keyMissing records the missing key, unless no key was expected since there is no active pub key
+func (m *KeyAvailabilityMonitor) keyMissing(ctx context.Context, height int64) error {
+	res, err := m.cosmosClient.GetActivePubKey(ctx)
+	if err != nil && !strings.Contains(err.Error(), "Active Public Key does not exists") {
+		return errors.Wrap(err, "error getting active pub key")
+	}
+	if res == nil || len(res.ActivePubkey.PublicKey) == 0 {
+		return nil
+	}
+
+	decryptionKeys.WithLabelValues(DecryptionKeyMissing).Inc()
+	m.missing++
+	decryptionKeysMissingConsecutive.Set(float64(m.missing))
+
+	if m.missing >= m.alertAfter {
+		decryptionKeyAlert.Set(1)
+		log.Printf("ALERT: No decryption key aggregated for the last %d heights, up to height %d\n", m.missing, height)
+	}
+	return nil
+}
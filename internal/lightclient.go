@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"ShareGenerationClient/config"
+	"ShareGenerationClient/pkg/cosmosClient"
+	"context"
+	"encoding/hex"
+	"github.com/pkg/errors"
+	"log"
+	"path/filepath"
+)
+
+// NewProofVerifier creates the light client backed proof verifier from config,
+// returns nil if light client verification is disabled
+func NewProofVerifier(ctx context.Context, cfg *config.Config) (*cosmosClient.ProofVerifier, error) {
+	if !cfg.LightClient.Enabled {
+		return nil, nil
+	}
+
+	trustedHash, err := hex.DecodeString(cfg.LightClient.TrustedHash)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid light client trusted hash")
+	}
+
+	configDir, err := config.GetConfigDir()
+	if err != nil {
+		return nil, err
+	}
+
+	verifier, err := cosmosClient.NewProofVerifier(ctx, cosmosClient.ProofVerifierOptions{
+		ChainID:        cfg.FairyRingNode.ChainID,
+		RPCEndpoint:    cfg.GetFairyRingNodeURI(),
+		Witnesses:      cfg.LightClient.Witnesses,
+		TrustedHeight:  cfg.LightClient.TrustedHeight,
+		TrustedHash:    trustedHash,
+		TrustingPeriod: cfg.LightClient.TrustingPeriod,
+		DBDir:          filepath.Join(configDir, "data"),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating light client, witnesses are set with --light-client-witnesses")
+	}
+
+	log.Printf("Light client verification enabled, trusted height: %d\n", cfg.LightClient.TrustedHeight)
+	return verifier, nil
+}
+
+// EnableProofVerification verifies validator infos of the main & all verification nodes clients with light client proofs
+func (sgc *ShareGeneratorClient) EnableProofVerification(verifier *cosmosClient.ProofVerifier) {
+	if verifier == nil {
+		return
+	}
+	sgc.CosmosClient.SetProofVerifier(verifier)
+	for _, node := range sgc.VerificationNodes {
+		node.CosmosClient.SetProofVerifier(verifier)
+	}
+	if len(sgc.VerificationNodes) == 0 {
+		log.Printf("Light client proofs do not verify the validator set is complete, validators omitted by the node are not detected without verification nodes\n")
+	}
+}
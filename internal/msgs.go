@@ -0,0 +1,60 @@
+package internal
+
+import (
+	"ShareGenerationClient/pkg/cosmosClient"
+	"github.com/Fairblock/fairyring/x/keyshare/types"
+	"github.com/pkg/errors"
+)
+
+// generate generates & checks the shares for the validators
+func (sgc *ShareGeneratorClient) generate(validatorsPubInfos []cosmosClient.ValidatorPubInfo) (*GenerateResult, error) {
+	generatedResult := sgc.Generate(validatorsPubInfos)
+	if generatedResult == nil {
+		return nil, Fatal(errors.New("generate result is empty"))
+	}
+	if err := generatedResult.Check(validatorsPubInfos); err != nil {
+		return nil, Fatal(errors.Wrap(err, "generate result check failed"))
+	}
+	return generatedResult, nil
+}
+
+// NewCreateLatestPubkeyMsg generates a queued pub key for the validators, the returned msg passed ValidateBasic
+func (sgc *ShareGeneratorClient) NewCreateLatestPubkeyMsg(validatorsPubInfos []cosmosClient.ValidatorPubInfo) (*types.MsgCreateLatestPubkey, *GenerateResult, error) {
+	generatedResult, err := sgc.generate(validatorsPubInfos)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	txMsg := types.MsgCreateLatestPubkey{
+		Creator:            sgc.CosmosClient.GetAddress(),
+		PublicKey:          generatedResult.MasterPublicKey,
+		Commitments:        generatedResult.Commitments,
+		NumberOfValidators: uint64(len(generatedResult.EncryptedKeyShares)),
+		EncryptedKeyshares: generatedResult.Keyshares(),
+	}
+	if err = txMsg.ValidateBasic(); err != nil {
+		return nil, nil, Fatal(errors.Wrap(err, "validate basic failed"))
+	}
+	return &txMsg, generatedResult, nil
+}
+
+// NewOverrideLatestPubkeyMsg generates a pub key overriding the active one for the validators,
+// the returned msg passed ValidateBasic
+func (sgc *ShareGeneratorClient) NewOverrideLatestPubkeyMsg(validatorsPubInfos []cosmosClient.ValidatorPubInfo) (*types.MsgOverrideLatestPubkey, *GenerateResult, error) {
+	generatedResult, err := sgc.generate(validatorsPubInfos)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	txMsg := types.MsgOverrideLatestPubkey{
+		Creator:            sgc.CosmosClient.GetAddress(),
+		PublicKey:          generatedResult.MasterPublicKey,
+		Commitments:        generatedResult.Commitments,
+		NumberOfValidators: uint64(len(generatedResult.EncryptedKeyShares)),
+		EncryptedKeyshares: generatedResult.Keyshares(),
+	}
+	if err = txMsg.ValidateBasic(); err != nil {
+		return nil, nil, Fatal(errors.Wrap(err, "validate basic failed"))
+	}
+	return &txMsg, generatedResult, nil
+}
@@ -0,0 +1,352 @@
+package internal
+
+import (
+	"ShareGenerationClient/config"
+	"ShareGenerationClient/pkg/cosmosClient"
+	"context"
+	stakingv1beta1 "cosmossdk.io/api/cosmos/staking/v1beta1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/Fairblock/fairyring/x/keyshare/types"
+	cosmostypes "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/tx"
+	dcrdSecp256k1 "github.com/decred/dcrd/dcrec/secp256k1"
+	"github.com/pkg/errors"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	OfflineMsgCreate   = "create"
+	OfflineMsgOverride = "override"
+)
+
+// ValidatorSetSnapshot is the validator set & account state exported for generating & signing a pub key offline
+type ValidatorSetSnapshot struct {
+	ChainID      string                  `json:"chain_id"`
+	Height       int64                   `json:"height"`
+	ExportedAt   time.Time               `json:"exported_at"`
+	Msg          string                  `json:"msg"`
+	Creator      string                  `json:"creator"`
+	ActivePubKey string                  `json:"active_pub_key"`
+	QueuedPubKey string                  `json:"queued_pub_key"`
+	Validators   []PlannedValidator      `json:"validators"`
+	SignParams   cosmosClient.SignParams `json:"sign_params"`
+}
+
+// SignedTxFile is a pub key tx signed offline, with the snapshot it was generated from
+type SignedTxFile struct {
+	Snapshot ValidatorSetSnapshot `json:"snapshot"`
+	Hash     string               `json:"hash"`
+	PubKey   string               `json:"pub_key"`
+	SignedAt time.Time            `json:"signed_at"`
+	TxBytes  string               `json:"tx_bytes"`
+}
+
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func readJSONFile(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func ReadValidatorSetSnapshot(path string) (*ValidatorSetSnapshot, error) {
+	var s ValidatorSetSnapshot
+	if err := readJSONFile(path, &s); err != nil {
+		return nil, errors.Wrap(err, "error reading validator set snapshot")
+	}
+	if len(s.Validators) == 0 {
+		return nil, errors.New("validator set snapshot has no validator")
+	}
+	if s.Msg != OfflineMsgCreate && s.Msg != OfflineMsgOverride {
+		return nil, errors.Errorf("unknown snapshot msg '%s', expected %s or %s", s.Msg, OfflineMsgCreate, OfflineMsgOverride)
+	}
+	return &s, nil
+}
+
+func (s *ValidatorSetSnapshot) Write(path string) error {
+	return errors.Wrap(writeJSONFile(path, s), "error writing validator set snapshot")
+}
+
+func ReadSignedTxFile(path string) (*SignedTxFile, error) {
+	var f SignedTxFile
+	if err := readJSONFile(path, &f); err != nil {
+		return nil, errors.Wrap(err, "error reading signed tx file")
+	}
+	return &f, nil
+}
+
+func (f *SignedTxFile) Write(path string) error {
+	return errors.Wrap(writeJSONFile(path, f), "error writing signed tx file")
+}
+
+// ValidatorsPubInfos returns the snapshot validators, in the order the shares are generated for
+func (s *ValidatorSetSnapshot) ValidatorsPubInfos() ([]cosmosClient.ValidatorPubInfo, error) {
+	validators := make([]cosmosClient.ValidatorPubInfo, 0, len(s.Validators))
+	for _, v := range s.Validators {
+		keyBytes, err := hex.DecodeString(v.PublicKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid validator %s pub key", v.Address)
+		}
+		pubKey, err := dcrdSecp256k1.ParsePubKey(keyBytes)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid validator %s pub key", v.Address)
+		}
+
+		info := cosmosClient.ValidatorPubInfo{
+			PublicKey:    pubKey,
+			Address:      v.Address,
+			AuthorizedBy: v.AuthorizedBy,
+		}
+		if len(v.AuthorizedBy) == 0 {
+			info.Description = &stakingv1beta1.Description{Moniker: v.Moniker}
+		}
+		validators = append(validators, info)
+	}
+	return validators, nil
+}
+
+// newPubkeyMsg generates the pub key msg of the snapshot kind for the validators
+func (sgc *ShareGeneratorClient) newPubkeyMsg(msgType string, validators []cosmosClient.ValidatorPubInfo) (cosmostypes.Msg, *GenerateResult, error) {
+	if msgType == OfflineMsgOverride {
+		return sgc.NewOverrideLatestPubkeyMsg(validators)
+	}
+	return sgc.NewCreateLatestPubkeyMsg(validators)
+}
+
+// ExportValidatorSet snapshots the verified validator set & the account state to sign the msg offline.
+// Without gas limit, it is simulated with a pub key generated for the snapshot & discarded
+func (sgc *ShareGeneratorClient) ExportValidatorSet(ctx context.Context, chainID, msgType string, gasLimit, timeoutBlocks uint64) (*ValidatorSetSnapshot, error) {
+	if msgType != OfflineMsgCreate && msgType != OfflineMsgOverride {
+		return nil, errors.Errorf("unknown msg '%s', expected %s or %s", msgType, OfflineMsgCreate, OfflineMsgOverride)
+	}
+
+	height, err := sgc.CosmosClient.GetChainHeight(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting chain height")
+	}
+
+	activePubKey, queuedPubKey, err := sgc.currentPubKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	validators, err := sgc.GetVerifiedValidatorsPubInfos(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting verified validators public infos")
+	}
+
+	rehearsal, _, err := sgc.newPubkeyMsg(msgType, validators)
+	if err != nil {
+		return nil, err
+	}
+	signParams, err := sgc.CosmosClient.ExportSignParams(ctx, rehearsal, gasLimit, timeoutBlocks)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ValidatorSetSnapshot{
+		ChainID:      chainID,
+		Height:       height,
+		ExportedAt:   time.Now().UTC(),
+		Msg:          msgType,
+		Creator:      sgc.CosmosClient.GetAddress(),
+		ActivePubKey: activePubKey,
+		QueuedPubKey: queuedPubKey,
+		Validators:   plannedValidators(validators),
+		SignParams:   *signParams,
+	}, nil
+}
+
+func (sgc *ShareGeneratorClient) currentPubKeys(ctx context.Context) (active, queued string, err error) {
+	res, err := sgc.CosmosClient.GetActivePubKey(ctx)
+	if err != nil && !strings.Contains(err.Error(), "Active Public Key does not exists") {
+		return "", "", errors.Wrap(err, "error getting pub keys")
+	}
+	if res == nil {
+		return "", "", nil
+	}
+	return res.ActivePubkey.PublicKey, res.QueuedPubkey.PublicKey, nil
+}
+
+// GenerateOffline generates the pub key for the snapshot validators & signs its msg, without any node connection
+func GenerateOffline(ctx context.Context, cfg *config.Config, snapshot *ValidatorSetSnapshot) (*SignedTxFile, error) {
+	cClient, err := cosmosClient.NewOfflineCosmosClient(cfg.PrivateKey, snapshot.SignParams)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't create offline client")
+	}
+	if cClient.GetAddress() != snapshot.Creator {
+		return nil, errors.Errorf("snapshot was exported for %s, configured key is %s", snapshot.Creator, cClient.GetAddress())
+	}
+
+	signMode, err := cosmosClient.ParseSignMode(cfg.SignMode)
+	if err != nil {
+		return nil, err
+	}
+	cClient.SetSignMode(signMode)
+
+	validators, err := snapshot.ValidatorsPubInfos()
+	if err != nil {
+		return nil, err
+	}
+
+	sgc := ShareGeneratorClient{CosmosClient: cClient}
+	txMsg, generatedResult, err := sgc.newPubkeyMsg(snapshot.Msg, validators)
+	if err != nil {
+		return nil, err
+	}
+
+	txBytes, hash, err := cClient.SignOffline(ctx, txMsg, snapshot.SignParams)
+	if err != nil {
+		return nil, errors.Wrap(err, "error signing tx")
+	}
+
+	return &SignedTxFile{
+		Snapshot: *snapshot,
+		Hash:     hash,
+		PubKey:   generatedResult.MasterPublicKey,
+		SignedAt: time.Now().UTC(),
+		TxBytes:  base64.StdEncoding.EncodeToString(txBytes),
+	}, nil
+}
+
+// checkSignedTx checks the signed tx is the pub key msg of the snapshot, for its validators in order
+func (sgc *ShareGeneratorClient) checkSignedTx(f *SignedTxFile, txBytes []byte) error {
+	info, err := sgc.CosmosClient.DecodeSignedTx(txBytes)
+	if err != nil {
+		return err
+	}
+	if info.Hash != f.Hash {
+		return errors.Errorf("tx hash %s does not match the recorded hash %s", info.Hash, f.Hash)
+	}
+	if len(info.Msgs) != 1 {
+		return errors.Errorf("expected 1 msg in tx, got %d", len(info.Msgs))
+	}
+
+	s := f.Snapshot
+	if info.Sequence != s.SignParams.Sequence {
+		return errors.Errorf("tx signed with sequence %d, snapshot sequence is %d", info.Sequence, s.SignParams.Sequence)
+	}
+	if info.TimeoutHeight != s.SignParams.TimeoutHeight {
+		return errors.Errorf("tx timeout height %d, snapshot timeout height is %d", info.TimeoutHeight, s.SignParams.TimeoutHeight)
+	}
+
+	var creator, pubKey string
+	var keyshares []*types.EncryptedKeyshare
+	switch msg := info.Msgs[0].(type) {
+	case *types.MsgCreateLatestPubkey:
+		if s.Msg != OfflineMsgCreate {
+			return errors.Errorf("tx has a create latest pubkey msg, snapshot msg is %s", s.Msg)
+		}
+		creator, pubKey, keyshares = msg.Creator, msg.PublicKey, msg.EncryptedKeyshares
+	case *types.MsgOverrideLatestPubkey:
+		if s.Msg != OfflineMsgOverride {
+			return errors.Errorf("tx has an override latest pubkey msg, snapshot msg is %s", s.Msg)
+		}
+		creator, pubKey, keyshares = msg.Creator, msg.PublicKey, msg.EncryptedKeyshares
+	default:
+		return errors.Errorf("unexpected msg %s in tx", cosmostypes.MsgTypeURL(msg))
+	}
+
+	if creator != s.Creator {
+		return errors.Errorf("tx creator %s, snapshot creator is %s", creator, s.Creator)
+	}
+	if pubKey != f.PubKey {
+		return errors.Errorf("tx pub key %s does not match the recorded pub key %s", pubKey, f.PubKey)
+	}
+	if len(keyshares) != len(s.Validators) {
+		return errors.Errorf("tx has %d keyshares, snapshot has %d validators", len(keyshares), len(s.Validators))
+	}
+	for i, ks := range keyshares {
+		if ks.Validator != s.Validators[i].Address {
+			return errors.Errorf("tx keyshare %d is for %s, snapshot validator is %s", i, ks.Validator, s.Validators[i].Address)
+		}
+	}
+	return nil
+}
+
+// CheckSnapshotCurrent checks the chain state the snapshot was exported from did not change:
+// same account sequence, pub keys & verified validator set, and the tx timeout height not reached
+func (sgc *ShareGeneratorClient) CheckSnapshotCurrent(ctx context.Context, chainID string, s *ValidatorSetSnapshot) error {
+	if s.ChainID != chainID {
+		return errors.Errorf("snapshot is for chain '%s', client is configured for '%s'", s.ChainID, chainID)
+	}
+
+	account, err := sgc.CosmosClient.GetAccount(ctx, s.Creator)
+	if err != nil {
+		return errors.Wrap(err, "error getting account")
+	}
+	if account.GetAccountNumber() != s.SignParams.AccountNumber {
+		return errors.Errorf("account number is %d, snapshot account number is %d", account.GetAccountNumber(), s.SignParams.AccountNumber)
+	}
+	if account.GetSequence() != s.SignParams.Sequence {
+		return errors.Errorf("account sequence is %d, snapshot sequence is %d", account.GetSequence(), s.SignParams.Sequence)
+	}
+
+	if s.SignParams.TimeoutHeight > 0 {
+		height, err := sgc.CosmosClient.GetChainHeight(ctx)
+		if err != nil {
+			return errors.Wrap(err, "error getting chain height")
+		}
+		if uint64(height) >= s.SignParams.TimeoutHeight {
+			return errors.Errorf("chain reached the tx timeout height %d", s.SignParams.TimeoutHeight)
+		}
+	}
+
+	activePubKey, queuedPubKey, err := sgc.currentPubKeys(ctx)
+	if err != nil {
+		return err
+	}
+	if activePubKey != s.ActivePubKey || queuedPubKey != s.QueuedPubKey {
+		return errors.New("active or queued pub key changed since the snapshot")
+	}
+
+	validators, err := sgc.GetVerifiedValidatorsPubInfos(ctx)
+	if err != nil {
+		return errors.Wrap(err, "error getting verified validators public infos")
+	}
+	current := make(map[string]string, len(validators))
+	for _, v := range validators {
+		current[v.Address] = encryptionKeyHex(v)
+	}
+	if len(current) != len(s.Validators) {
+		return errors.Errorf("validator set has %d validators, snapshot has %d", len(current), len(s.Validators))
+	}
+	for _, v := range s.Validators {
+		key, found := current[v.Address]
+		if !found {
+			return errors.Errorf("snapshot validator %s is not in the validator set anymore", v.Address)
+		}
+		if key != v.PublicKey {
+			return errors.Errorf("snapshot validator %s encryption key changed", v.Address)
+		}
+	}
+	return nil
+}
+
+// BroadcastOffline checks the tx signed offline & the snapshot it was generated from are still current,
+// then broadcasts it and waits until it is included
+func (sgc *ShareGeneratorClient) BroadcastOffline(ctx context.Context, chainID string, f *SignedTxFile) (*tx.GetTxResponse, error) {
+	txBytes, err := base64.StdEncoding.DecodeString(f.TxBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding tx bytes")
+	}
+	if err = sgc.checkSignedTx(f, txBytes); err != nil {
+		return nil, errors.Wrap(err, "signed tx does not match its snapshot")
+	}
+	if err = sgc.CheckSnapshotCurrent(ctx, chainID, &f.Snapshot); err != nil {
+		return nil, errors.Wrap(err, "snapshot is outdated, export & generate again")
+	}
+	return sgc.CosmosClient.BroadcastSignedTx(ctx, txBytes, f.Snapshot.SignParams.TimeoutHeight, time.Second)
+}
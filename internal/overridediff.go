@@ -0,0 +1,211 @@
+package internal
+
+import (
+	"ShareGenerationClient/pkg/cosmosClient"
+	"context"
+	"cosmossdk.io/math"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"sort"
+	"text/tabwriter"
+)
+
+const (
+	DiffGainShare                = "gain-share"
+	DiffLoseShare                = "lose-share"
+	DiffAuthorizedAddressChanged = "authorized-address-changed"
+	DiffEncryptionKeyChanged     = "encryption-key-changed"
+	DiffUnchanged                = "unchanged"
+
+	DiffFormatTable = "table"
+	DiffFormatJSON  = "json"
+)
+
+// OverrideDiff compares the active pub key holders with the validators an override generates the new pub key for
+type OverrideDiff struct {
+	Changes          []ValidatorChange `json:"changes"`
+	OldHolders       int               `json:"old_holders"`
+	NewHolders       int               `json:"new_holders"`
+	OldThreshold     int               `json:"old_threshold"`
+	NewThreshold     int               `json:"new_threshold"`
+	OldStakeCoverage float64           `json:"old_stake_coverage"`
+	NewStakeCoverage float64           `json:"new_stake_coverage"`
+}
+
+// ValidatorChange is what the override changes for a validator. The share address is the authorized address if any,
+// the encryption key is the pub key of the share address account
+type ValidatorChange struct {
+	Validator       string `json:"validator"`
+	Moniker         string `json:"moniker,omitempty"`
+	Change          string `json:"change"`
+	Stake           string `json:"stake"`
+	OldShareAddress string `json:"old_share_address,omitempty"`
+	NewShareAddress string `json:"new_share_address,omitempty"`
+	OldPublicKey    string `json:"old_public_key,omitempty"`
+	NewPublicKey    string `json:"new_public_key,omitempty"`
+}
+
+type shareInfo struct {
+	address   string
+	publicKey string
+}
+
+// DiffOverride compares the active pub key holders with the validators selected for the override,
+// numberOfValidators is the number of validators the active pub key was generated for
+func (sgc *ShareGeneratorClient) DiffOverride(ctx context.Context, holders, proposed []cosmosClient.ValidatorPubInfo, numberOfValidators uint64) (*OverrideDiff, error) {
+	// Shares were encrypted to the keys pinned when they were generated, the current account keys may have changed since
+	pinned := make(map[string]ValidatorPin)
+	if len(sgc.PinStorePath) > 0 {
+		store, err := LoadPinStore(sgc.PinStorePath)
+		if err != nil {
+			return nil, err
+		}
+		pinned = store.Pinned
+	}
+
+	old := make(map[string]shareInfo, len(holders))
+	for _, h := range holders {
+		share := shareInfo{address: shareHolder(h), publicKey: encryptionKeyHex(h)}
+		if pin, found := pinned[h.Address]; found && pin.Target == share.address {
+			share.publicKey = pin.PublicKey
+		} else if len(h.Authorizing) > 0 {
+			// Holders are listed with the validator account key, the share was encrypted to the authorized address
+			pubKey, err := sgc.CosmosClient.GetAccountPubKey(ctx, h.Authorizing)
+			if err != nil {
+				return nil, errors.Wrapf(err, "error getting authorized address %s pub key", h.Authorizing)
+			}
+			share.publicKey = encryptionKeyHex(cosmosClient.ValidatorPubInfo{PublicKey: pubKey})
+		}
+		old[h.Address] = share
+	}
+
+	proposedShares := make(map[string]shareInfo, len(proposed))
+	for _, v := range proposed {
+		validator := v.Address
+		if len(v.AuthorizedBy) > 0 {
+			validator = v.AuthorizedBy
+		}
+		proposedShares[validator] = shareInfo{address: v.Address, publicKey: encryptionKeyHex(v)}
+	}
+
+	stakes, total, err := sgc.CosmosClient.GetBondedStakes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return compareOverride(old, proposedShares, int(numberOfValidators), stakes, total), nil
+}
+
+// compareOverride returns the changes between the old & new shares, keyed by validator address
+func compareOverride(old, proposed map[string]shareInfo, oldHolders int, stakes map[string]cosmosClient.ValidatorStake, total math.Int) *OverrideDiff {
+	d := OverrideDiff{
+		Changes:      make([]ValidatorChange, 0),
+		OldHolders:   oldHolders,
+		NewHolders:   len(proposed),
+		OldThreshold: Threshold(oldHolders),
+		NewThreshold: Threshold(len(proposed)),
+	}
+
+	oldStake, newStake := math.ZeroInt(), math.ZeroInt()
+	validators := make(map[string]bool, len(old)+len(proposed))
+	for v := range old {
+		validators[v] = true
+	}
+	for v := range proposed {
+		validators[v] = true
+	}
+
+	for validator := range validators {
+		before, held := old[validator]
+		after, kept := proposed[validator]
+
+		stake, bonded := stakes[validator]
+		if !bonded {
+			stake.Tokens = math.ZeroInt()
+		}
+		if held {
+			oldStake = oldStake.Add(stake.Tokens)
+		}
+		if kept {
+			newStake = newStake.Add(stake.Tokens)
+		}
+
+		c := ValidatorChange{
+			Validator:       validator,
+			Moniker:         stake.Moniker,
+			Stake:           stake.Tokens.String(),
+			OldShareAddress: before.address,
+			NewShareAddress: after.address,
+			OldPublicKey:    before.publicKey,
+			NewPublicKey:    after.publicKey,
+		}
+		switch {
+		case !held:
+			c.Change = DiffGainShare
+		case !kept:
+			c.Change = DiffLoseShare
+		case before.address != after.address:
+			c.Change = DiffAuthorizedAddressChanged
+		case before.publicKey != after.publicKey:
+			c.Change = DiffEncryptionKeyChanged
+		default:
+			c.Change = DiffUnchanged
+		}
+		d.Changes = append(d.Changes, c)
+	}
+
+	sort.Slice(d.Changes, func(i, j int) bool {
+		if d.Changes[i].Change != d.Changes[j].Change {
+			return d.Changes[i].Change < d.Changes[j].Change
+		}
+		return d.Changes[i].Validator < d.Changes[j].Validator
+	})
+
+	if total.IsPositive() {
+		d.OldStakeCoverage = math.LegacyNewDecFromInt(oldStake).QuoInt(total).MustFloat64()
+		d.NewStakeCoverage = math.LegacyNewDecFromInt(newStake).QuoInt(total).MustFloat64()
+	}
+	return &d
+}
+
+// Write renders the diff as a table or JSON
+func (d *OverrideDiff) Write(w io.Writer, format string) error {
+	switch format {
+	case DiffFormatJSON:
+		data, err := json.MarshalIndent(d, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "error encoding override diff")
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	case DiffFormatTable, "":
+		return d.writeTable(w)
+	default:
+		return errors.Errorf("unknown diff format '%s', expected %s or %s", format, DiffFormatTable, DiffFormatJSON)
+	}
+}
+
+func (d *OverrideDiff) writeTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHANGE\tMONIKER\tVALIDATOR\tSTAKE\tSHARE ADDRESS")
+	for _, c := range d.Changes {
+		shareAddress := c.NewShareAddress
+		switch c.Change {
+		case DiffLoseShare:
+			shareAddress = c.OldShareAddress
+		case DiffAuthorizedAddressChanged:
+			shareAddress = c.OldShareAddress + " -> " + c.NewShareAddress
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", c.Change, c.Moniker, c.Validator, c.Stake, shareAddress)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(
+		w, "Holders: %d -> %d | Threshold: %d -> %d | Stake Coverage: %.2f%% -> %.2f%%\n",
+		d.OldHolders, d.NewHolders, d.OldThreshold, d.NewThreshold, d.OldStakeCoverage*100, d.NewStakeCoverage*100,
+	)
+	return err
+}
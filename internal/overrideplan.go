@@ -0,0 +1,191 @@
+package internal
+
+import (
+	"ShareGenerationClient/pkg/cosmosClient"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OverridePlan is a reviewed selection of the validators the overriding pub key is generated for
+type OverridePlan struct {
+	ChainID    string             `json:"chain_id"`
+	Height     int64              `json:"height"`
+	CreatedAt  time.Time          `json:"created_at"`
+	Threshold  int                `json:"threshold"`
+	Validators []PlannedValidator `json:"validators"`
+	Excluded   []PlannedValidator `json:"excluded"`
+}
+
+// PlannedValidator is a validator of the plan, with the encryption key its share is encrypted to
+type PlannedValidator struct {
+	Address      string `json:"address"`
+	Moniker      string `json:"moniker,omitempty"`
+	AuthorizedBy string `json:"authorized_by,omitempty"`
+	PublicKey    string `json:"public_key"`
+}
+
+// ValidatorLabel returns the validator moniker, or who authorized the address if it is an authorized address
+func ValidatorLabel(v cosmosClient.ValidatorPubInfo) string {
+	if v.Description == nil {
+		return "Authorized By " + v.AuthorizedBy
+	}
+	return v.Description.Moniker
+}
+
+func encryptionKeyHex(v cosmosClient.ValidatorPubInfo) string {
+	if v.PublicKey == nil {
+		return ""
+	}
+	return hex.EncodeToString(v.PublicKey.SerializeCompressed())
+}
+
+// SelectValidators returns the validators matching the include selectors, all if there is none,
+// without the ones matching the exclude selectors. A selector is an index in the list, an address or a moniker
+func SelectValidators(validators []cosmosClient.ValidatorPubInfo, include, exclude []string) (selected, excluded []cosmosClient.ValidatorPubInfo, err error) {
+	included := make([]bool, len(validators))
+	for i := range included {
+		included[i] = len(include) == 0
+	}
+
+	for _, selector := range include {
+		matches, err := matchValidators(validators, selector)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "invalid include selector")
+		}
+		for _, i := range matches {
+			included[i] = true
+		}
+	}
+
+	for _, selector := range exclude {
+		matches, err := matchValidators(validators, selector)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "invalid exclude selector")
+		}
+		for _, i := range matches {
+			included[i] = false
+		}
+	}
+
+	for i, v := range validators {
+		if included[i] {
+			selected = append(selected, v)
+		} else {
+			excluded = append(excluded, v)
+		}
+	}
+
+	if len(selected) == 0 {
+		return nil, nil, errors.New("no validator selected")
+	}
+	return selected, excluded, nil
+}
+
+func matchValidators(validators []cosmosClient.ValidatorPubInfo, selector string) ([]int, error) {
+	selector = strings.TrimSpace(selector)
+
+	if index, err := strconv.Atoi(selector); err == nil {
+		if index < 0 || index >= len(validators) {
+			return nil, errors.Errorf("index %d out of range, expected 0 to %d", index, len(validators)-1)
+		}
+		return []int{index}, nil
+	}
+
+	matches := make([]int, 0)
+	for i, v := range validators {
+		if v.Address == selector || v.AuthorizedBy == selector || (v.Description != nil && strings.EqualFold(v.Description.Moniker, selector)) {
+			matches = append(matches, i)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, errors.Errorf("no validator found for '%s'", selector)
+	}
+	return matches, nil
+}
+
+func plannedValidators(validators []cosmosClient.ValidatorPubInfo) []PlannedValidator {
+	planned := make([]PlannedValidator, 0, len(validators))
+	for _, v := range validators {
+		p := PlannedValidator{
+			Address:      v.Address,
+			AuthorizedBy: v.AuthorizedBy,
+			PublicKey:    encryptionKeyHex(v),
+		}
+		if v.Description != nil {
+			p.Moniker = v.Description.Moniker
+		}
+		planned = append(planned, p)
+	}
+	return planned
+}
+
+func NewOverridePlan(chainID string, height int64, selected, excluded []cosmosClient.ValidatorPubInfo) *OverridePlan {
+	return &OverridePlan{
+		ChainID:    chainID,
+		Height:     height,
+		CreatedAt:  time.Now().UTC(),
+		Threshold:  Threshold(len(selected)),
+		Validators: plannedValidators(selected),
+		Excluded:   plannedValidators(excluded),
+	}
+}
+
+// ReadOverridePlan reads the plan from the JSON file
+func ReadOverridePlan(path string) (*OverridePlan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading override plan")
+	}
+
+	var plan OverridePlan
+	if err = json.Unmarshal(data, &plan); err != nil {
+		return nil, errors.Wrap(err, "error decoding override plan")
+	}
+	if len(plan.Validators) == 0 {
+		return nil, errors.New("override plan has no validator")
+	}
+	return &plan, nil
+}
+
+// Write writes the plan to the JSON file
+func (p *OverridePlan) Write(path string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "error encoding override plan")
+	}
+	if err = os.WriteFile(path, data, 0644); err != nil {
+		return errors.Wrap(err, "error writing override plan")
+	}
+	return nil
+}
+
+// Resolve returns the planned validators from the current validator set,
+// failing if one of them left it or changed its encryption key since the plan was reviewed
+func (p *OverridePlan) Resolve(chainID string, validators []cosmosClient.ValidatorPubInfo) ([]cosmosClient.ValidatorPubInfo, error) {
+	if p.ChainID != chainID {
+		return nil, errors.Errorf("override plan is for chain '%s', client is configured for '%s'", p.ChainID, chainID)
+	}
+
+	current := make(map[string]cosmosClient.ValidatorPubInfo, len(validators))
+	for _, v := range validators {
+		current[v.Address] = v
+	}
+
+	resolved := make([]cosmosClient.ValidatorPubInfo, 0, len(p.Validators))
+	for _, planned := range p.Validators {
+		v, found := current[planned.Address]
+		if !found {
+			return nil, errors.Errorf("planned validator %s is not in the validator set anymore", planned.Address)
+		}
+		if encryptionKeyHex(v) != planned.PublicKey {
+			return nil, errors.Errorf("planned validator %s encryption key changed since the plan was made", planned.Address)
+		}
+		resolved = append(resolved, v)
+	}
+	return resolved, nil
+}
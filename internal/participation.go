@@ -0,0 +1,185 @@
+package internal
+
+import (
+	"ShareGenerationClient/config"
+	"ShareGenerationClient/pkg/cosmosClient"
+	"context"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"log"
+	"slices"
+	"sync"
+)
+
+var (
+	validatorParticipation = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "sharegenerationclient_validator_participation",
+		Help: "The fraction of the observed heights the active pub key holder submitted its keyshare for",
+	}, []string{"address"})
+
+	keysharesMissed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sharegenerationclient_keyshares_missed",
+		Help: "The total number of heights the active pub key holder did not submit its keyshare for",
+	}, []string{"address"})
+
+	heightKeyshares = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "sharegenerationclient_height_keyshares",
+		Help: "The number of active pub key holders that submitted their keyshare for the latest observed height",
+	})
+
+	participationHeight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "sharegenerationclient_participation_height",
+		Help: "The latest height the keyshare submissions were observed for",
+	})
+)
+
+// ValidatorParticipation is how many keyshares an active pub key holder submitted over the observed heights
+type ValidatorParticipation struct {
+	Address   string
+	Submitted int
+	Observed  int
+	Rate      float64
+}
+
+// ParticipationMonitor tracks which active pub key holders submitted their keyshare over a sliding window of heights
+type ParticipationMonitor struct {
+	cosmosClient *cosmosClient.CosmosClient
+	window       int
+
+	mu          sync.Mutex
+	holders     []string
+	submissions map[string][]bool
+	lastHeight  int64
+}
+
+func NewParticipationMonitor(cfg *config.Config, cClient *cosmosClient.CosmosClient) *ParticipationMonitor {
+	window := int(cfg.Participation.Window)
+	if window <= 0 {
+		window = config.DefaultParticipationWindow
+	}
+	return &ParticipationMonitor{
+		cosmosClient: cClient,
+		window:       window,
+		submissions:  make(map[string][]bool),
+	}
+}
+
+// Run observes the keyshare submissions up to every new block until ctx is done
+func (m *ParticipationMonitor) Run(ctx context.Context, blocks <-chan Block) {
+	for {
+		select {
+		case block := <-blocks:
+			if err := m.ObserveUpTo(ctx, block.Height); err != nil && ctx.Err() == nil {
+				log.Printf("Unable to observe keyshare submissions at height %d: %s\n", block.Height, err.Error())
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// ObserveUpTo observes the heights following the last observed one up to the given height,
+// at most the window size. Keyshares are submitted up to the height they are for, so the height has to be committed
+func (m *ParticipationMonitor) ObserveUpTo(ctx context.Context, height int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if height <= m.lastHeight {
+		return nil
+	}
+
+	holders, err := m.cosmosClient.GetActivePubKeyHolders(ctx)
+	if err != nil {
+		return errors.Wrap(err, "error getting active pub key holders")
+	}
+	m.setHolders(holders)
+
+	from := max(m.lastHeight+1, height-int64(m.window)+1, 1)
+	for h := from; h <= height; h++ {
+		if err = m.observe(ctx, h); err != nil {
+			return err
+		}
+		m.lastHeight = h
+	}
+
+	for _, holder := range m.holders {
+		validatorParticipation.WithLabelValues(holder).Set(m.participation(holder).Rate)
+	}
+	participationHeight.Set(float64(m.lastHeight))
+	return nil
+}
+
+// setHolders starts tracking the holders of a new active pub key, dropping the ones not holding a share anymore
+func (m *ParticipationMonitor) setHolders(holders []string) {
+	for _, holder := range m.holders {
+		if !slices.Contains(holders, holder) {
+			delete(m.submissions, holder)
+			validatorParticipation.DeleteLabelValues(holder)
+			keysharesMissed.DeleteLabelValues(holder)
+		}
+	}
+	m.holders = holders
+}
+
+func (m *ParticipationMonitor) observe(ctx context.Context, height int64) error {
+	submitted := 0
+	for _, holder := range m.holders {
+		_, found, err := m.cosmosClient.GetKeyshare(ctx, holder, uint64(height))
+		if err != nil {
+			return errors.Wrapf(err, "error getting %s keyshare for height %d", holder, height)
+		}
+
+		if found {
+			submitted++
+		} else {
+			keysharesMissed.WithLabelValues(holder).Inc()
+		}
+
+		s := append(m.submissions[holder], found)
+		if len(s) > m.window {
+			s = s[len(s)-m.window:]
+		}
+		m.submissions[holder] = s
+	}
+	heightKeyshares.Set(float64(submitted))
+	return nil
+}
+
+func (m *ParticipationMonitor) participation(holder string) ValidatorParticipation {
+	p := ValidatorParticipation{Address: holder}
+	for _, found := range m.submissions[holder] {
+		p.Observed++
+		if found {
+			p.Submitted++
+		}
+	}
+	if p.Observed > 0 {
+		p.Rate = float64(p.Submitted) / float64(p.Observed)
+	}
+	return p
+}
+
+// Report returns the participation of every active pub key holder over the observed window
+func (m *ParticipationMonitor) Report() []ValidatorParticipation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	report := make([]ValidatorParticipation, 0, len(m.holders))
+	for _, holder := range m.holders {
+		report = append(report, m.participation(holder))
+	}
+	return report
+}
+
+// LastHeight returns the latest observed height
+func (m *ParticipationMonitor) LastHeight() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lastHeight
+}
+
+// Window returns the number of heights the participation is computed over
+func (m *ParticipationMonitor) Window() int {
+	return m.window
+}
@@ -0,0 +1,22 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package internal
+
+import (
+	"os"
+	"sync"
+)
+
+// pinLock only serialises pin store updates within the process, no file lock is available on this platform
+var pinLock sync.Mutex
+
+// lockFile blocks until it holds the process wide pin store lock
+func lockFile(_ *os.File) error {
+	pinLock.Lock()
+	return nil
+}
+
+func unlockFile(_ *os.File) error {
+	pinLock.Unlock()
+	return nil
+}
@@ -0,0 +1,226 @@
+package internal
+
+import (
+	"ShareGenerationClient/config"
+	"ShareGenerationClient/pkg/cosmosClient"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const PinStoreFileName = "pins.json"
+
+var pendingPinChanges = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "sharegenerationclient_pending_pin_changes",
+	Help: "The number of validators encryption key / authorization changes waiting for operator approval",
+})
+
+// ValidatorPin is the encryption key & share holder of a validator as seen on first use
+type ValidatorPin struct {
+	Validator string    `json:"validator"`
+	Target    string    `json:"target"`
+	PublicKey string    `json:"public_key"`
+	FirstSeen time.Time `json:"first_seen"`
+}
+
+// PinStore keeps the pinned validators encryption keys, keyed by validator address
+type PinStore struct {
+	Pinned  map[string]ValidatorPin `json:"pinned"`
+	Pending map[string]ValidatorPin `json:"pending"`
+	path    string
+}
+
+func DefaultPinStorePath() (string, error) {
+	configDir, err := config.GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, PinStoreFileName), nil
+}
+
+func LoadPinStore(path string) (*PinStore, error) {
+	store := PinStore{
+		Pinned:  make(map[string]ValidatorPin),
+		Pending: make(map[string]ValidatorPin),
+		path:    path,
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &store, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading pin store")
+	}
+
+	if err = json.Unmarshal(data, &store); err != nil {
+		return nil, errors.Wrap(err, "error decoding pin store")
+	}
+	if store.Pinned == nil {
+		store.Pinned = make(map[string]ValidatorPin)
+	}
+	if store.Pending == nil {
+		store.Pending = make(map[string]ValidatorPin)
+	}
+
+	return &store, nil
+}
+
+// Save writes the pin store to a temp file renamed over the previous one, so it is never read half written
+func (ps *PinStore) Save() error {
+	data, err := json.MarshalIndent(ps, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(ps.path), "."+PinStoreFileName+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), ps.path)
+}
+
+// UpdatePinStore loads, updates & saves the pin store while holding an exclusive lock,
+// so the client & the pins command never overwrite each other changes
+func UpdatePinStore(path string, update func(store *PinStore) error) error {
+	lock, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return errors.Wrap(err, "error opening pin store lock")
+	}
+	defer lock.Close()
+
+	if err = lockFile(lock); err != nil {
+		return errors.Wrap(err, "error locking pin store")
+	}
+	defer unlockFile(lock)
+
+	store, err := LoadPinStore(path)
+	if err != nil {
+		return err
+	}
+	if err = update(store); err != nil {
+		return err
+	}
+	if err = store.Save(); err != nil {
+		return errors.Wrap(err, "error saving pin store")
+	}
+	return nil
+}
+
+func toValidatorPin(record validatorRecord, now time.Time) ValidatorPin {
+	pin := ValidatorPin{
+		Validator: record.Address,
+		Target:    record.Address,
+		PublicKey: record.PublicKey,
+		FirstSeen: now,
+	}
+	if len(record.AuthorizedBy) > 0 {
+		pin.Validator = record.AuthorizedBy
+	}
+	return pin
+}
+
+// Check pins every validator seen for the first time, and returns the validators address
+// that have a different encryption key or authorization target than the pinned one.
+// Changes are kept as pending until accepted
+func (ps *PinStore) Check(validatorsPubInfos []cosmosClient.ValidatorPubInfo) []string {
+	now := time.Now()
+	changed := make([]string, 0)
+
+	for _, record := range toValidatorRecords(validatorsPubInfos) {
+		current := toValidatorPin(record, now)
+
+		pinned, found := ps.Pinned[current.Validator]
+		if !found {
+			log.Printf("Pinning validator %s encryption key %s, share holder: %s\n", current.Validator, current.PublicKey, current.Target)
+			ps.Pinned[current.Validator] = current
+			continue
+		}
+
+		if pinned.PublicKey == current.PublicKey && pinned.Target == current.Target {
+			delete(ps.Pending, current.Validator)
+			continue
+		}
+
+		if pending, found := ps.Pending[current.Validator]; found &&
+			pending.PublicKey == current.PublicKey && pending.Target == current.Target {
+			current.FirstSeen = pending.FirstSeen
+		}
+		ps.Pending[current.Validator] = current
+		changed = append(changed, current.Validator)
+	}
+
+	sort.Strings(changed)
+	pendingPinChanges.Set(float64(len(ps.Pending)))
+	return changed
+}
+
+// Accept replaces the pinned encryption key & target of the validator by the pending one
+func (ps *PinStore) Accept(validator string) error {
+	pending, found := ps.Pending[validator]
+	if !found {
+		return errors.Errorf("no pending change for validator %s", validator)
+	}
+	ps.Pinned[validator] = pending
+	delete(ps.Pending, validator)
+	return nil
+}
+
+// CheckPins compares the validators with the pin store, and returns an error if any of them changed
+// encryption key or authorization target and the pin policy requires the operator approval
+func (sgc *ShareGeneratorClient) CheckPins(validatorsPubInfos []cosmosClient.ValidatorPubInfo) error {
+	if len(sgc.PinStorePath) == 0 {
+		return nil
+	}
+
+	var changed []string
+	var pendingChanges int
+	err := UpdatePinStore(sgc.PinStorePath, func(store *PinStore) error {
+		changed = store.Check(validatorsPubInfos)
+
+		for _, v := range changed {
+			pinned, pending := store.Pinned[v], store.Pending[v]
+			log.Printf(
+				"ALERT: Validator %s changed encryption key %s => %s, share holder %s => %s\n",
+				v, pinned.PublicKey, pending.PublicKey, pinned.Target, pending.Target,
+			)
+			if sgc.PinPolicy == config.PinPolicyAccept {
+				_ = store.Accept(v)
+			}
+		}
+		pendingChanges = len(store.Pending)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(changed) > 0 && sgc.PinPolicy != config.PinPolicyAccept {
+		return Fatal(errors.Errorf(
+			"%d validator(s) changed encryption key or share holder, review & run 'pins accept' to continue",
+			len(changed),
+		))
+	}
+
+	pendingPinChanges.Set(float64(pendingChanges))
+	return nil
+}
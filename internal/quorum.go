@@ -0,0 +1,152 @@
+package internal
+
+import (
+	"ShareGenerationClient/config"
+	"ShareGenerationClient/pkg/cosmosClient"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"log"
+	"sort"
+)
+
+var (
+	validatorSetMismatch = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sharegenerationclient_validator_set_mismatch",
+		Help: "The total number of times a verification node returned a different validator set than the main node",
+	}, []string{"endpoint"})
+
+	validatorSetQuorumFailed = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "sharegenerationclient_validator_set_quorum_failed",
+		Help: "The total number of times the validator set did not reach the verification quorum",
+	})
+)
+
+type VerificationNode struct {
+	Endpoint     string
+	CosmosClient *cosmosClient.CosmosClient
+}
+
+// validatorRecord is the part of ValidatorPubInfo that decides who is able to decrypt a share
+type validatorRecord struct {
+	Address      string
+	AuthorizedBy string
+	PublicKey    string
+}
+
+func NewVerificationNodes(ctx context.Context, cfg *config.Config) ([]VerificationNode, error) {
+	nodes := make([]VerificationNode, 0, len(cfg.VerificationNodes))
+	for _, n := range cfg.VerificationNodes {
+		endpoint := n.GetGRPCEndpoint()
+		cClient, err := cosmosClient.NewCosmosClient(ctx, endpoint, cfg.PrivateKey, cfg.FairyRingNode.ChainID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error creating cosmos client for verification node %s", endpoint)
+		}
+		nodes = append(nodes, VerificationNode{
+			Endpoint:     endpoint,
+			CosmosClient: cClient,
+		})
+	}
+	return nodes, nil
+}
+
+func toValidatorRecords(validatorsPubInfos []cosmosClient.ValidatorPubInfo) map[string]validatorRecord {
+	records := make(map[string]validatorRecord, len(validatorsPubInfos))
+	for _, v := range validatorsPubInfos {
+		record := validatorRecord{
+			Address:      v.Address,
+			AuthorizedBy: v.AuthorizedBy,
+		}
+		if v.PublicKey != nil {
+			record.PublicKey = hex.EncodeToString(v.PublicKey.SerializeCompressed())
+		}
+		records[v.Address] = record
+	}
+	return records
+}
+
+// diffValidatorRecords returns a human-readable line for every difference between the expected and the actual set
+func diffValidatorRecords(expected, actual map[string]validatorRecord) []string {
+	diffs := make([]string, 0)
+
+	for addr, e := range expected {
+		a, found := actual[addr]
+		if !found {
+			diffs = append(diffs, fmt.Sprintf("- %s: missing", addr))
+			continue
+		}
+		if e.PublicKey != a.PublicKey {
+			diffs = append(diffs, fmt.Sprintf("~ %s: public key %s != %s", addr, e.PublicKey, a.PublicKey))
+		}
+		if e.AuthorizedBy != a.AuthorizedBy {
+			diffs = append(diffs, fmt.Sprintf("~ %s: authorized by '%s' != '%s'", addr, e.AuthorizedBy, a.AuthorizedBy))
+		}
+	}
+
+	for addr, a := range actual {
+		if _, found := expected[addr]; !found {
+			diffs = append(diffs, fmt.Sprintf("+ %s: unexpected, public key %s, authorized by '%s'", addr, a.PublicKey, a.AuthorizedBy))
+		}
+	}
+
+	sort.Strings(diffs)
+	return diffs
+}
+
+// GetVerifiedValidatorsPubInfos returns the validators public infos from the main node
+// only if enough verification nodes returned exactly the same addresses, public keys & authorizations,
+// and none of the validators encryption key changed from the pinned one
+func (sgc *ShareGeneratorClient) GetVerifiedValidatorsPubInfos(ctx context.Context) ([]cosmosClient.ValidatorPubInfo, error) {
+	validatorsPubInfos, err := sgc.CosmosClient.GetAllValidatorsPubInfos(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sgc.VerificationNodes) == 0 {
+		return sgc.checkedAgainstPins(validatorsPubInfos)
+	}
+
+	expected := toValidatorRecords(validatorsPubInfos)
+	var agreed uint64 = 1
+
+	for _, node := range sgc.VerificationNodes {
+		nodeValidatorsPubInfos, err := node.CosmosClient.GetAllValidatorsPubInfos(ctx)
+		if err != nil {
+			log.Printf("Unable to get validators public infos from verification node %s: %s\n", node.Endpoint, err.Error())
+			continue
+		}
+
+		diffs := diffValidatorRecords(expected, toValidatorRecords(nodeValidatorsPubInfos))
+		if len(diffs) == 0 {
+			agreed++
+			continue
+		}
+
+		validatorSetMismatch.WithLabelValues(node.Endpoint).Inc()
+		log.Printf("ALERT: Verification node %s returned a different validator set than the main node:\n", node.Endpoint)
+		for _, d := range diffs {
+			log.Printf("  %s\n", d)
+		}
+	}
+
+	if agreed < sgc.VerificationQuorum {
+		validatorSetQuorumFailed.Inc()
+		return nil, errors.Errorf(
+			"validator set verification failed, only %d / %d nodes agreed, required: %d",
+			agreed, len(sgc.VerificationNodes)+1, sgc.VerificationQuorum,
+		)
+	}
+
+	log.Printf("Validator set verified, %d / %d nodes agreed\n", agreed, len(sgc.VerificationNodes)+1)
+	return sgc.checkedAgainstPins(validatorsPubInfos)
+}
+
+func (sgc *ShareGeneratorClient) checkedAgainstPins(validatorsPubInfos []cosmosClient.ValidatorPubInfo) ([]cosmosClient.ValidatorPubInfo, error) {
+	if err := sgc.CheckPins(validatorsPubInfos); err != nil {
+		return nil, err
+	}
+	return validatorsPubInfos, nil
+}
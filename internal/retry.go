@@ -0,0 +1,167 @@
+package internal
+
+import (
+	"ShareGenerationClient/config"
+	"ShareGenerationClient/pkg/cosmosClient"
+	"context"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log"
+	"math/rand"
+	"time"
+)
+
+const (
+	ErrorClassTransient = "transient"
+	ErrorClassFatal     = "fatal"
+)
+
+var (
+	clientDegraded = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "sharegenerationclient_degraded",
+		Help: "1 if the latest pub key check failed, 0 once a check succeeds again",
+	})
+
+	checkFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sharegenerationclient_check_failed",
+		Help: "The total number of pub key checks that failed, by class of the error that stopped them",
+	}, []string{"class"})
+
+	checkRetries = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "sharegenerationclient_check_retries",
+		Help: "The total number of operations retried after a transient error",
+	})
+)
+
+// fatalError is an error that retrying the same operation can not fix
+type fatalError struct {
+	error
+}
+
+func (e fatalError) Unwrap() error {
+	return e.error
+}
+
+func Fatal(err error) error {
+	if err == nil {
+		return nil
+	}
+	return fatalError{err}
+}
+
+// ClassifyError returns whether the error is worth retrying, errors are transient unless
+// they are marked as fatal or the node rejected the request or tx itself
+func ClassifyError(err error) string {
+	var fatal fatalError
+	if errors.As(err, &fatal) {
+		return ErrorClassFatal
+	}
+
+	// Tx expired after its last rebroadcast, retrying would only sign it again
+	if errors.Is(err, cosmosClient.ErrTxExpired) {
+		return ErrorClassFatal
+	}
+
+	var rejected *cosmosClient.TxRejectedError
+	if errors.As(err, &rejected) && !rejected.Temporary() {
+		return ErrorClassFatal
+	}
+
+	if s, ok := status.FromError(err); ok {
+		switch s.Code() {
+		case codes.InvalidArgument, codes.NotFound, codes.AlreadyExists, codes.PermissionDenied,
+			codes.Unauthenticated, codes.FailedPrecondition, codes.OutOfRange, codes.Unimplemented:
+			return ErrorClassFatal
+		}
+	}
+
+	return ErrorClassTransient
+}
+
+// RetryPolicy bounds how many times, in total, the operations of a single check are retried
+type RetryPolicy struct {
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Budget         uint64
+}
+
+// NewRetryPolicy returns the configured retry policy, unset backoffs are replaced by the default ones
+func NewRetryPolicy(cfg *config.Config) RetryPolicy {
+	policy := RetryPolicy{
+		InitialBackoff: cfg.Retry.InitialBackoff,
+		MaxBackoff:     cfg.Retry.MaxBackoff,
+		Budget:         cfg.Retry.Budget,
+	}
+	if policy.InitialBackoff <= 0 {
+		policy.InitialBackoff = config.DefaultRetryInitialBackoff
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = config.DefaultRetryMaxBackoff
+	}
+	return policy
+}
+
+// Backoff returns the exponential delay before the given retry, with the upper half jittered
+func (p RetryPolicy) Backoff(retry uint64) time.Duration {
+	delay := p.MaxBackoff
+	if retry < 32 && p.InitialBackoff<<retry < p.MaxBackoff {
+		delay = p.InitialBackoff << retry
+	}
+	if delay <= 0 {
+		return 0
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
+
+// retrier retries transient errors until the retry budget of the check is spent
+type retrier struct {
+	policy  RetryPolicy
+	retries uint64
+}
+
+func (p RetryPolicy) newRetrier() *retrier {
+	return &retrier{policy: p}
+}
+
+func (r *retrier) Do(ctx context.Context, operation string, fn func() error) error {
+	for {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		if ctx.Err() != nil || ClassifyError(err) == ErrorClassFatal {
+			return errors.Wrap(err, operation)
+		}
+
+		if r.retries >= r.policy.Budget {
+			return errors.Wrapf(err, "%s, retry budget of %d exhausted", operation, r.policy.Budget)
+		}
+
+		delay := r.policy.Backoff(r.retries)
+		r.retries++
+		checkRetries.Inc()
+		log.Printf("%s failed: %s, retrying in %s (%d / %d)\n", operation, err.Error(), delay, r.retries, r.policy.Budget)
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(err, operation)
+		case <-time.After(delay):
+		}
+	}
+}
+
+// recordCheckResult exports the daemon as degraded until a check succeeds again
+func recordCheckResult(err error) {
+	if err == nil {
+		clientDegraded.Set(0)
+		return
+	}
+	class := ClassifyError(err)
+	checkFailed.WithLabelValues(class).Inc()
+	clientDegraded.Set(1)
+	log.Printf("Pub key check failed (%s), client degraded: %s\n", class, err.Error())
+}
@@ -0,0 +1,120 @@
+package internal
+
+import (
+	"ShareGenerationClient/config"
+	peptypes "github.com/Fairblock/fairyring/x/pep/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"time"
+)
+
+var (
+	activePubkeyBlocksToExpiry = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "sharegenerationclient_active_pubkey_blocks_to_expiry",
+		Help: "The number of blocks before the active pub key expires",
+	})
+
+	activePubkeyExpiryETA = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "sharegenerationclient_active_pubkey_expiry_eta_seconds",
+		Help: "The estimated time before the active pub key expires, 0 if the block time is unknown",
+	})
+
+	pubkeyExpiryAlert = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "sharegenerationclient_pubkey_expiry_alert",
+		Help: "1 if the active pub key is about to expire without any queued pub key, 0 otherwise",
+	})
+
+	nextCheckHeight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "sharegenerationclient_next_check_height",
+		Help: "The height of the next scheduled pub key check",
+	})
+)
+
+// ExpirySchedule decides when the queued pub key is generated, relative to the active pub key expiry
+type ExpirySchedule struct {
+	GenerateBlocks uint64
+	GenerateBefore time.Duration
+	AlertBlocks    uint64
+}
+
+// SchedulePlan is what to do at a check, and when to check next
+type SchedulePlan struct {
+	Generate   bool
+	Alert      bool
+	BlocksLeft int64
+	NextCheck  int64
+	HasActive  bool
+	HasQueued  bool
+}
+
+func NewExpirySchedule(cfg *config.Config) ExpirySchedule {
+	return ExpirySchedule{
+		GenerateBlocks: cfg.Schedule.GenerateBeforeExpiryBlocks,
+		GenerateBefore: cfg.Schedule.GenerateBeforeExpiry,
+		AlertBlocks:    cfg.Schedule.AlertBeforeExpiryBlocks,
+	}
+}
+
+// immediate returns true when no generation window is configured,
+// the queued pub key is then generated as soon as there is none
+func (s ExpirySchedule) immediate() bool {
+	return s.GenerateBlocks == 0 && s.GenerateBefore <= 0
+}
+
+// generateWindow returns how many blocks before the active pub key expiry the queued one is generated,
+// the largest of the configured blocks and the configured duration converted with the block time,
+// or with FastBlockTime while the block time is unknown
+func (s ExpirySchedule) generateWindow(blockTime time.Duration) uint64 {
+	blockTime = blockTimeOr(blockTime, FastBlockTime)
+	window := s.GenerateBlocks
+	if s.GenerateBefore > 0 {
+		if blocks := uint64((s.GenerateBefore + blockTime - 1) / blockTime); blocks > window {
+			window = blocks
+		}
+	}
+	return window
+}
+
+// Plan returns whether the queued pub key has to be generated at the given height, and when to check next.
+// A zero NextCheck means no schedule can be derived from the pub keys and the check interval applies
+func (s ExpirySchedule) Plan(height int64, res *peptypes.QueryPubkeyResponse, blockTime time.Duration) SchedulePlan {
+	var p SchedulePlan
+	if res != nil {
+		p.HasActive = len(res.ActivePubkey.PublicKey) > 0
+		p.HasQueued = len(res.QueuedPubkey.PublicKey) > 0 || len(res.QueuedPubkey.Creator) > 0
+	}
+
+	if !p.HasActive {
+		p.Generate = !p.HasQueued
+		return p
+	}
+
+	p.BlocksLeft = int64(res.ActivePubkey.Expiry) - height
+
+	if p.HasQueued {
+		// Queued pub key becomes the active one once the current one expires
+		p.NextCheck = int64(res.ActivePubkey.Expiry) + 1
+		return p
+	}
+
+	window := int64(s.generateWindow(blockTime))
+	p.Alert = p.BlocksLeft <= int64(s.AlertBlocks)
+
+	if s.immediate() || p.BlocksLeft <= window {
+		p.Generate = true
+		return p
+	}
+
+	p.NextCheck = int64(res.ActivePubkey.Expiry) - window
+	return p
+}
+
+func recordSchedulePlan(p SchedulePlan, estimator *BlockTimeEstimator) {
+	activePubkeyBlocksToExpiry.Set(float64(p.BlocksLeft))
+	activePubkeyExpiryETA.Set(estimator.ETA(p.BlocksLeft).Seconds())
+	if p.Alert {
+		pubkeyExpiryAlert.Set(1)
+	} else {
+		pubkeyExpiryAlert.Set(0)
+	}
+}
@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	commontypes "github.com/Fairblock/fairyring/x/common/types"
+	peptypes "github.com/Fairblock/fairyring/x/pep/types"
+)
+
+func TestExpirySchedulePlan(t *testing.T) {
+	active := func(expiry uint64) *peptypes.QueryPubkeyResponse {
+		return &peptypes.QueryPubkeyResponse{
+			ActivePubkey: commontypes.ActivePublicKey{PublicKey: "active", Expiry: expiry},
+		}
+	}
+
+	tests := []struct {
+		name         string
+		schedule     ExpirySchedule
+		height       int64
+		res          *peptypes.QueryPubkeyResponse
+		blockTime    time.Duration
+		wantGenerate bool
+		wantNext     int64
+	}{
+		{
+			name:         "no active pub key",
+			schedule:     ExpirySchedule{GenerateBlocks: 50},
+			height:       100,
+			res:          &peptypes.QueryPubkeyResponse{},
+			wantGenerate: true,
+		},
+		{
+			name:     "queued pub key checked after expiry",
+			schedule: ExpirySchedule{GenerateBlocks: 50},
+			height:   100,
+			res: &peptypes.QueryPubkeyResponse{
+				ActivePubkey: commontypes.ActivePublicKey{PublicKey: "active", Expiry: 1000},
+				QueuedPubkey: commontypes.QueuedPublicKey{PublicKey: "queued"},
+			},
+			wantNext: 1001,
+		},
+		{
+			name:         "no window generates right away",
+			height:       100,
+			res:          active(1000),
+			wantGenerate: true,
+		},
+		{
+			name:     "blocks window not reached",
+			schedule: ExpirySchedule{GenerateBlocks: 50},
+			height:   100,
+			res:      active(1000),
+			wantNext: 950,
+		},
+		{
+			name:         "blocks window reached",
+			schedule:     ExpirySchedule{GenerateBlocks: 50},
+			height:       950,
+			res:          active(1000),
+			wantGenerate: true,
+		},
+		{
+			name:      "duration window with estimated block time",
+			schedule:  ExpirySchedule{GenerateBefore: 10 * time.Minute},
+			height:    100,
+			res:       active(1000),
+			blockTime: 5 * time.Second,
+			wantNext:  880,
+		},
+		{
+			name:      "duration window rounded up to whole blocks",
+			schedule:  ExpirySchedule{GenerateBefore: 10 * time.Minute},
+			height:    100,
+			res:       active(1000),
+			blockTime: 7 * time.Second,
+			wantNext:  914,
+		},
+		{
+			name:     "duration window with unknown block time uses fast block time",
+			schedule: ExpirySchedule{GenerateBefore: 5 * time.Minute},
+			height:   100,
+			res:      active(1000),
+			wantNext: 700,
+		},
+		{
+			name:         "duration window with unknown block time reached",
+			schedule:     ExpirySchedule{GenerateBefore: 5 * time.Minute},
+			height:       750,
+			res:          active(1000),
+			wantGenerate: true,
+		},
+		{
+			name:      "largest of blocks & duration window",
+			schedule:  ExpirySchedule{GenerateBlocks: 200, GenerateBefore: 5 * time.Minute},
+			height:    100,
+			res:       active(1000),
+			blockTime: 6 * time.Second,
+			wantNext:  800,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.schedule.Plan(tt.height, tt.res, tt.blockTime)
+			if p.Generate != tt.wantGenerate {
+				t.Fatalf("Plan() Generate = %t, want %t", p.Generate, tt.wantGenerate)
+			}
+			if p.NextCheck != tt.wantNext {
+				t.Fatalf("Plan() NextCheck = %d, want %d", p.NextCheck, tt.wantNext)
+			}
+		})
+	}
+}
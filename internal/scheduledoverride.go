@@ -0,0 +1,218 @@
+package internal
+
+import (
+	"ShareGenerationClient/config"
+	"ShareGenerationClient/pkg/cosmosClient"
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+const ScheduledOverrideFileName = "scheduled_override.json"
+
+var ErrScheduledOverrideCancelled = errors.New("scheduled override cancelled")
+
+// ScheduledOverride is an override waiting for its target height, the validators are selected again at that height.
+// It is recorded to a file while it waits, removing the file cancels it
+type ScheduledOverride struct {
+	ChainID     string        `json:"chain_id"`
+	Height      int64         `json:"height"`
+	ScheduledAt time.Time     `json:"scheduled_at"`
+	PID         int           `json:"pid"`
+	Include     []string      `json:"include,omitempty"`
+	Exclude     []string      `json:"exclude,omitempty"`
+	Plan        *OverridePlan `json:"plan,omitempty"`
+}
+
+func DefaultScheduledOverridePath() (string, error) {
+	configDir, err := config.GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, ScheduledOverrideFileName), nil
+}
+
+// NewScheduledOverride schedules an override for the given validator selection,
+// an empty include keeps all validators but the excluded ones
+func NewScheduledOverride(chainID string, height int64, include, exclude []cosmosClient.ValidatorPubInfo) *ScheduledOverride {
+	addresses := func(validators []cosmosClient.ValidatorPubInfo) []string {
+		list := make([]string, 0, len(validators))
+		for _, v := range validators {
+			list = append(list, v.Address)
+		}
+		return list
+	}
+
+	return &ScheduledOverride{
+		ChainID:     chainID,
+		Height:      height,
+		ScheduledAt: time.Now().UTC(),
+		PID:         os.Getpid(),
+		Include:     addresses(include),
+		Exclude:     addresses(exclude),
+	}
+}
+
+// ReadScheduledOverride returns the override scheduled in the file, nil if there is none
+func ReadScheduledOverride(path string) (*ScheduledOverride, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading scheduled override")
+	}
+
+	var s ScheduledOverride
+	if err = json.Unmarshal(data, &s); err != nil {
+		return nil, errors.Wrap(err, "error decoding scheduled override")
+	}
+	return &s, nil
+}
+
+// Save records the override to the file, failing if another override is already scheduled
+func (s *ScheduledOverride) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "error encoding scheduled override")
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		return errors.Errorf("an override is already scheduled in %s, cancel it first", path)
+	}
+	if err != nil {
+		return errors.Wrap(err, "error creating scheduled override")
+	}
+	defer f.Close()
+
+	if _, err = f.Write(data); err != nil {
+		return errors.Wrap(err, "error writing scheduled override")
+	}
+	return nil
+}
+
+// Active returns true while the override is still the one recorded in the file
+func (s *ScheduledOverride) Active(path string) (bool, error) {
+	recorded, err := ReadScheduledOverride(path)
+	if err != nil || recorded == nil {
+		return false, err
+	}
+	return recorded.PID == s.PID && recorded.ScheduledAt.Equal(s.ScheduledAt), nil
+}
+
+// Remove deletes the file if it still records this override
+func (s *ScheduledOverride) Remove(path string) error {
+	active, err := s.Active(path)
+	if err != nil || !active {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// CancelScheduledOverride removes the scheduled override file, returning the cancelled override, nil if there was none
+func CancelScheduledOverride(path string) (*ScheduledOverride, error) {
+	s, err := ReadScheduledOverride(path)
+	if err != nil || s == nil {
+		return s, err
+	}
+	if err = os.Remove(path); err != nil {
+		return nil, errors.Wrap(err, "error removing scheduled override")
+	}
+	return s, nil
+}
+
+// Resolve selects the validators from the validator set at the target height.
+// Excluded validators that already left are ignored, but included ones have to still be there
+func (s *ScheduledOverride) Resolve(chainID string, validators []cosmosClient.ValidatorPubInfo) (selected, excluded []cosmosClient.ValidatorPubInfo, err error) {
+	if s.ChainID != chainID {
+		return nil, nil, errors.Errorf("override is scheduled for chain '%s', client is configured for '%s'", s.ChainID, chainID)
+	}
+	if s.Plan != nil {
+		selected, err = s.Plan.Resolve(chainID, validators)
+		return selected, nil, err
+	}
+
+	found := make(map[string]bool, len(validators))
+	for _, v := range validators {
+		found[v.Address] = true
+		if (len(s.Include) == 0 || slices.Contains(s.Include, v.Address)) && !slices.Contains(s.Exclude, v.Address) {
+			selected = append(selected, v)
+		} else {
+			excluded = append(excluded, v)
+		}
+	}
+
+	for _, address := range s.Include {
+		if !found[address] {
+			return nil, nil, errors.Errorf("included validator %s is not in the validator set anymore", address)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, nil, errors.New("no validator selected")
+	}
+	return selected, excluded, nil
+}
+
+// WaitForScheduledOverride follows new blocks until the target height of the override,
+// then returns the validators selected from the validator set at that height.
+// It fails if the node already pruned the state of that height
+func (sgc *ShareGeneratorClient) WaitForScheduledOverride(ctx context.Context, cfg *config.Config, s *ScheduledOverride, path string) (selected, excluded []cosmosClient.ValidatorPubInfo, err error) {
+	var estimator BlockTimeEstimator
+	if err = estimator.Seed(ctx, sgc.CosmosClient); err != nil {
+		log.Printf("Unable to estimate block time from recent blocks: %s\n", err.Error())
+	}
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	heights := make(chan Block, 1)
+	go NewHeightWatcher(cfg, sgc.CosmosClient).Run(watchCtx, heights)
+
+	for block := range heights {
+		active, err := s.Active(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !active {
+			return nil, nil, ErrScheduledOverrideCancelled
+		}
+
+		estimator.Observe(block)
+		if block.Height >= s.Height {
+			log.Printf("Reached height %d, selecting the validators for the scheduled override...\n", block.Height)
+			break
+		}
+		log.Printf(
+			"Latest Block Height: %d | Scheduled override at height %d, ETA: %s\n",
+			block.Height, s.Height, estimator.FormatETA(s.Height-block.Height),
+		)
+	}
+	if ctx.Err() != nil {
+		return nil, nil, ctx.Err()
+	}
+
+	// Validators are queried at the target height, even if the latest one is past it after retries
+	var validators []cosmosClient.ValidatorPubInfo
+	heightCtx := cosmosClient.AtHeight(ctx, s.Height)
+	retry := NewRetryPolicy(cfg).newRetrier()
+	err = retry.Do(ctx, "getting verified validators public infos", func() error {
+		var err error
+		validators, err = sgc.GetVerifiedValidatorsPubInfos(heightCtx)
+		if cosmosClient.IsHeightPruned(err) {
+			return Fatal(errors.Wrapf(err, "state at height %d is pruned, the validator set can not be resolved at the scheduled height", s.Height))
+		}
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log.Printf("Validator set resolved at height %d for the scheduled override\n", s.Height)
+	return s.Resolve(cfg.FairyRingNode.ChainID, validators)
+}
@@ -0,0 +1,135 @@
+package internal
+
+import (
+	"ShareGenerationClient/config"
+	"ShareGenerationClient/pkg/cosmosClient"
+	"context"
+	"encoding/json"
+	"github.com/cosmos/cosmos-sdk/types/tx"
+	"github.com/pkg/errors"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// ExitCodeOK is returned when the client stopped without any tx in flight
+	ExitCodeOK = 0
+	// ExitCodeError is returned when the client failed to start
+	ExitCodeError = 1
+	// ExitCodeTxInFlight is returned when the client stopped before knowing if a submitted tx was included
+	ExitCodeTxInFlight = 2
+
+	InFlightTxFileName = "inflight_tx.json"
+)
+
+// InFlightTx is a submitted tx not known to be included when the client stopped
+type InFlightTx struct {
+	Hash          string    `json:"hash"`
+	Sequence      uint64    `json:"sequence"`
+	TimeoutHeight uint64    `json:"timeout_height"`
+	RecordedAt    time.Time `json:"recorded_at"`
+}
+
+func DefaultInFlightTxPath() (string, error) {
+	configDir, err := config.GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, InFlightTxFileName), nil
+}
+
+// Close closes the gRPC connections to the main & verification nodes
+func (sgc *ShareGeneratorClient) Close() {
+	if err := sgc.CosmosClient.Close(); err != nil {
+		log.Printf("Unable to close gRPC connection: %s\n", err.Error())
+	}
+	for _, node := range sgc.VerificationNodes {
+		if err := node.CosmosClient.Close(); err != nil {
+			log.Printf("Unable to close gRPC connection to %s: %s\n", node.Endpoint, err.Error())
+		}
+	}
+}
+
+// FinishInFlightTx waits for the tx interrupted by shutdown for up to the shutdown timeout,
+// and records it to be resolved on next start if it is still not included
+func (sgc *ShareGeneratorClient) FinishInFlightTx(inFlight *cosmosClient.InFlightTxError) error {
+	log.Printf("Shutting down with tx %s in flight, waiting up to %s for it\n", inFlight.Hash, sgc.ShutdownTimeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), sgc.ShutdownTimeout)
+	defer cancel()
+
+	resp, err := sgc.CosmosClient.WaitForTx(ctx, inFlight.Hash, inFlight.TimeoutHeight, time.Second)
+	if errors.Is(err, cosmosClient.ErrTxExpired) {
+		log.Printf("In flight tx %s expired without being included\n", inFlight.Hash)
+		return nil
+	}
+	if err == nil {
+		recordSubmissionResult(resp)
+		return nil
+	}
+
+	record := InFlightTx{
+		Hash:          inFlight.Hash,
+		Sequence:      inFlight.Sequence,
+		TimeoutHeight: inFlight.TimeoutHeight,
+		RecordedAt:    time.Now(),
+	}
+	if saveErr := saveInFlightTx(sgc.InFlightTxPath, record); saveErr != nil {
+		return errors.Wrapf(saveErr, "tx %s still in flight and could not be recorded", inFlight.Hash)
+	}
+	return errors.Errorf("tx %s still in flight, recorded to %s", inFlight.Hash, sgc.InFlightTxPath)
+}
+
+// ResolveRecordedInFlightTx reports the result of the tx recorded as in flight on last shutdown, if any
+func (sgc *ShareGeneratorClient) ResolveRecordedInFlightTx(ctx context.Context) error {
+	data, err := os.ReadFile(sgc.InFlightTxPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var record InFlightTx
+	if err = json.Unmarshal(data, &record); err != nil {
+		return errors.Wrap(err, "error decoding in flight tx")
+	}
+
+	log.Printf("Resolving tx %s in flight since %s\n", record.Hash, record.RecordedAt.Format(time.RFC3339))
+
+	waitCtx, cancel := context.WithTimeout(ctx, sgc.ShutdownTimeout)
+	defer cancel()
+
+	resp, err := sgc.CosmosClient.WaitForTx(waitCtx, record.Hash, record.TimeoutHeight, time.Second)
+	switch {
+	case errors.Is(err, cosmosClient.ErrTxExpired):
+		log.Printf("In flight tx %s expired without being included\n", record.Hash)
+	case err != nil:
+		return errors.Wrapf(err, "tx %s is still unknown", record.Hash)
+	default:
+		recordSubmissionResult(resp)
+	}
+
+	return os.Remove(sgc.InFlightTxPath)
+}
+
+// recordSubmissionResult counts the in flight pub key tx as a valid or failed share generation
+func recordSubmissionResult(resp *tx.GetTxResponse) {
+	if resp.TxResponse.Code != 0 {
+		failedShareGenerated.Inc()
+		log.Printf("In flight tx %s failed: %s\n", resp.TxResponse.TxHash, resp.TxResponse.RawLog)
+		return
+	}
+	validShareGenerated.Inc()
+	log.Printf("In flight tx %s included at height %d\n", resp.TxResponse.TxHash, resp.TxResponse.Height)
+}
+
+func saveInFlightTx(path string, record InFlightTx) error {
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
@@ -0,0 +1,120 @@
+package internal
+
+import (
+	"ShareGenerationClient/pkg/cosmosClient"
+	"context"
+	"fmt"
+	keysharetypes "github.com/Fairblock/fairyring/x/keyshare/types"
+	coretypes "github.com/cometbft/cometbft/rpc/core/types"
+	tmtypes "github.com/cometbft/cometbft/types"
+	cosmostypes "github.com/cosmos/cosmos-sdk/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"log"
+)
+
+const (
+	TriggerQueuedPubkeyCreated      = "queued pub key created"
+	TriggerPubkeyOverrode           = "pub key overrode"
+	TriggerValidatorRegistered      = "validator registered"
+	TriggerValidatorDeregistered    = "validator deregistered"
+	TriggerAuthorizedAddressChanged = "authorized address changed"
+)
+
+var checkTriggers = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "sharegenerationclient_check_triggers",
+	Help: "The total number of keyshare module events triggering a pub key check",
+}, []string{"event"})
+
+// CheckTrigger is a keyshare module event making the pub key check run right away
+type CheckTrigger struct {
+	Event  string
+	Height int64
+}
+
+// triggerQueries match the keyshare msgs changing the pub keys or the validator set the pub key is generated for.
+// Queries can not be OR'ed, msgs sharing a name part are matched by one query and told apart by their action,
+// keeping the subscriptions under the node max_subscriptions_per_client (5 by default).
+// Queued pub key activation emits no event, it is covered by the check scheduled at the active pub key expiry
+var triggerQueries = []string{
+	msgActionContainsQuery("LatestPubkey"),
+	msgActionContainsQuery("RegisterValidator"),
+	msgActionContainsQuery("AuthorizedAddress"),
+}
+
+// triggerActions are the msg actions triggering a check, other msgs matched by triggerQueries are ignored
+var triggerActions = map[string]string{
+	cosmostypes.MsgTypeURL(&keysharetypes.MsgCreateLatestPubkey{}):      TriggerQueuedPubkeyCreated,
+	cosmostypes.MsgTypeURL(&keysharetypes.MsgOverrideLatestPubkey{}):    TriggerPubkeyOverrode,
+	cosmostypes.MsgTypeURL(&keysharetypes.MsgRegisterValidator{}):       TriggerValidatorRegistered,
+	cosmostypes.MsgTypeURL(&keysharetypes.MsgDeRegisterValidator{}):     TriggerValidatorDeregistered,
+	cosmostypes.MsgTypeURL(&keysharetypes.MsgCreateAuthorizedAddress{}): TriggerAuthorizedAddressChanged,
+	cosmostypes.MsgTypeURL(&keysharetypes.MsgUpdateAuthorizedAddress{}): TriggerAuthorizedAddressChanged,
+	cosmostypes.MsgTypeURL(&keysharetypes.MsgDeleteAuthorizedAddress{}): TriggerAuthorizedAddressChanged,
+}
+
+func msgActionContainsQuery(action string) string {
+	return fmt.Sprintf("tm.event = 'Tx' AND message.action CONTAINS '%s'", action)
+}
+
+// subscribeTriggers subscribes to every trigger query on a websocket client of its own, so the block header & tx
+// subscriptions keep their slots, and forwards the events until ctx is done or the subscriptions are closed.
+// Failed subscriptions are alerted, the check interval still applies
+func subscribeTriggers(ctx context.Context, dial func() (eventClient, error), triggers chan<- CheckTrigger) {
+	client, err := dial()
+	if err != nil {
+		log.Printf("ALERT: Unable to create trigger websocket client, checking every check interval only: %s\n", err.Error())
+		return
+	}
+	if err = client.Start(); err != nil {
+		log.Printf("ALERT: Unable to start trigger websocket client, checking every check interval only: %s\n", err.Error())
+		return
+	}
+	go func() {
+		<-ctx.Done()
+		if err := client.Stop(); err != nil {
+			log.Printf("Unable to stop trigger websocket client: %s\n", err.Error())
+		}
+	}()
+
+	for _, query := range triggerQueries {
+		subscribeCtx, cancel := context.WithTimeout(ctx, cosmosClient.SubscribeTimeout)
+		out, err := client.Subscribe(subscribeCtx, "", query)
+		cancel()
+		if err != nil {
+			log.Printf("ALERT: Subscription to \"%s\" rejected, these events will not trigger a check: %s\n", query, err.Error())
+			continue
+		}
+		go forwardTriggers(ctx, out, triggers)
+	}
+}
+
+func forwardTriggers(ctx context.Context, out <-chan coretypes.ResultEvent, triggers chan<- CheckTrigger) {
+	for {
+		select {
+		case result, ok := <-out:
+			if !ok {
+				return
+			}
+			data, ok := result.Data.(tmtypes.EventDataTx)
+			if !ok {
+				continue
+			}
+			for _, action := range result.Events["message.action"] {
+				event, found := triggerActions[action]
+				if !found {
+					continue
+				}
+				checkTriggers.WithLabelValues(event).Inc()
+
+				// A pending trigger already makes the check run, so the new one can be dropped
+				select {
+				case triggers <- CheckTrigger{Event: event, Height: data.Height}:
+				default:
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
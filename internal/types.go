@@ -1,20 +1,103 @@
 package internal
 
 import (
+	"ShareGenerationClient/config"
 	"ShareGenerationClient/pkg/cosmosClient"
+	"context"
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
 	distIBE "github.com/FairBlock/DistributedIBE"
+	"github.com/Fairblock/fairyring/x/keyshare/types"
 	dcrdSecp256k1 "github.com/decred/dcrd/dcrec/secp256k1"
 	"github.com/drand/kyber"
 	bls "github.com/drand/kyber-bls12381"
+	"github.com/pkg/errors"
 	"math"
 	"math/big"
+	"time"
 )
 
 type ShareGeneratorClient struct {
-	CosmosClient *cosmosClient.CosmosClient
+	CosmosClient       *cosmosClient.CosmosClient
+	VerificationNodes  []VerificationNode
+	VerificationQuorum uint64
+	PinStorePath       string
+	PinPolicy          string
+	InFlightTxPath     string
+	ShutdownTimeout    time.Duration
+	Schedule           ExpirySchedule
+	OverridePolicy     OverridePolicy
+}
+
+func NewShareGeneratorClient(ctx context.Context, cfg *config.Config) (*ShareGeneratorClient, error) {
+	cClient, err := cosmosClient.NewCosmosClient(ctx, cfg.GetGRPCEndpoint(), cfg.PrivateKey, cfg.FairyRingNode.ChainID)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't create cosmos client")
+	}
+
+	signMode, err := cosmosClient.ParseSignMode(cfg.SignMode)
+	if err != nil {
+		return nil, err
+	}
+	cClient.SetSignMode(signMode)
+
+	feeOptions, err := cosmosClient.ParseFeeOptions(
+		cfg.Fee.GasPrice, cfg.Fee.MaxFee, cfg.Fee.Granter, cfg.FairyRingNode.Denom,
+	)
+	if err != nil {
+		return nil, err
+	}
+	feeOptions.Auto = cfg.Fee.Auto
+	feeOptions.MaxBumps = cfg.Fee.MaxBumps
+	if feeOptions.BumpFactor, err = cosmosClient.ParseBumpFactor(cfg.Fee.BumpFactor); err != nil {
+		return nil, err
+	}
+	cClient.SetFeeOptions(feeOptions)
+	cClient.SetTxOptions(cosmosClient.TxOptions{
+		TimeoutBlocks:   cfg.Tx.TimeoutBlocks,
+		MaxRebroadcasts: cfg.Tx.MaxRebroadcasts,
+	})
+
+	verificationNodes, err := NewVerificationNodes(ctx, cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't create verification nodes clients")
+	}
+
+	pinStorePath, err := DefaultPinStorePath()
+	if err != nil {
+		return nil, err
+	}
+
+	inFlightTxPath, err := DefaultInFlightTxPath()
+	if err != nil {
+		return nil, err
+	}
+
+	shutdownTimeout := cfg.ShutdownTimeout
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = config.DefaultShutdownTimeout
+	}
+
+	sgc := ShareGeneratorClient{
+		CosmosClient:       cClient,
+		VerificationNodes:  verificationNodes,
+		VerificationQuorum: cfg.GetVerificationQuorum(),
+		PinStorePath:       pinStorePath,
+		PinPolicy:          cfg.PinPolicy,
+		InFlightTxPath:     inFlightTxPath,
+		ShutdownTimeout:    shutdownTimeout,
+		Schedule:           NewExpirySchedule(cfg),
+		OverridePolicy:     NewOverridePolicy(cfg),
+	}
+
+	proofVerifier, err := NewProofVerifier(ctx, cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't create light client proof verifier")
+	}
+	sgc.EnableProofVerification(proofVerifier)
+
+	return &sgc, nil
 }
 
 type EncryptedShare struct {
@@ -30,10 +113,73 @@ type GenerateResult struct {
 	MasterPublicKey    string
 }
 
+// Threshold returns the number of keyshares needed to aggregate a decryption key out of n
+func Threshold(n int) int {
+	return int(math.Ceil(float64(n) * (2.0 / 3.0)))
+}
+
+// Check verifies the result has a commitment & a share for each of the validators it was generated for
+func (r *GenerateResult) Check(validatorsPubInfos []cosmosClient.ValidatorPubInfo) error {
+	n := len(validatorsPubInfos)
+	if len(r.EncryptedKeyShares) != n {
+		return errors.Errorf("expected %d encrypted key shares, got %d", n, len(r.EncryptedKeyShares))
+	}
+	if len(r.Commitments) != n {
+		return errors.Errorf("expected %d commitments, got %d", n, len(r.Commitments))
+	}
+
+	suite := bls.NewBLS12381Suite()
+	masterPublicKeyByte, err := hex.DecodeString(r.MasterPublicKey)
+	if err != nil {
+		return errors.Wrap(err, "error decoding master public key")
+	}
+	if err = suite.G1().Point().UnmarshalBinary(masterPublicKeyByte); err != nil {
+		return errors.Wrap(err, "invalid master public key")
+	}
+
+	validators := make(map[string]bool, n)
+	for _, v := range validatorsPubInfos {
+		validators[v.Address] = true
+	}
+
+	for i, share := range r.EncryptedKeyShares {
+		if share == nil {
+			return errors.Errorf("missing encrypted key share for index %d", i+1)
+		}
+		if !validators[share.ValidatorAddress] {
+			return errors.Errorf("encrypted key share %d is for an unknown or duplicated validator: %s", i+1, share.ValidatorAddress)
+		}
+		delete(validators, share.ValidatorAddress)
+
+		commitmentByte, err := hex.DecodeString(r.Commitments[i])
+		if err != nil {
+			return errors.Wrapf(err, "error decoding commitment %d", i+1)
+		}
+		if err = suite.G1().Point().UnmarshalBinary(commitmentByte); err != nil {
+			return errors.Wrapf(err, "invalid commitment %d", i+1)
+		}
+	}
+	return nil
+}
+
+// Keyshares returns the encrypted key shares ordered by share index, as expected by the keyshare module msgs
+func (r *GenerateResult) Keyshares() []*types.EncryptedKeyshare {
+	encShares := make([]*types.EncryptedKeyshare, len(r.EncryptedKeyShares))
+	for _, v := range r.EncryptedKeyShares {
+		indexByte, _ := hex.DecodeString(v.Index.String())
+		indexInt := big.NewInt(0).SetBytes(indexByte).Uint64()
+		encShares[indexInt-1] = &types.EncryptedKeyshare{
+			Data:      v.EncShare,
+			Validator: v.ValidatorAddress,
+		}
+	}
+	return encShares
+}
+
 func (sgc *ShareGeneratorClient) Generate(validatorsPubInfos []cosmosClient.ValidatorPubInfo) *GenerateResult {
 
 	n := len(validatorsPubInfos)
-	t := int(math.Ceil(float64(n) * (2.0 / 3.0)))
+	t := Threshold(n)
 
 	shares, mpk, _, err := distIBE.GenerateShares(uint32(n), uint32(t))
 	if err != nil {
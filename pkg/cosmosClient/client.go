@@ -3,11 +3,14 @@ package cosmosClient
 import (
 	"context"
 	"encoding/hex"
+	"fmt"
 	"log"
 	"strings"
+	"sync"
 	"time"
 
 	stakingv1beta1 "cosmossdk.io/api/cosmos/staking/v1beta1"
+	errorsmod "cosmossdk.io/errors"
 	"cosmossdk.io/math"
 	"github.com/Fairblock/fairyring/api/fairyring/keyshare"
 	"github.com/Fairblock/fairyring/x/pep/types"
@@ -15,6 +18,7 @@ import (
 	"github.com/cosmos/cosmos-sdk/crypto/keys/secp256k1"
 	cryptotypes "github.com/cosmos/cosmos-sdk/crypto/types"
 	cosmostypes "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
 	"github.com/cosmos/cosmos-sdk/types/tx"
 	"github.com/cosmos/cosmos-sdk/types/tx/signing"
 	authsigning "github.com/cosmos/cosmos-sdk/x/auth/signing"
@@ -22,13 +26,15 @@ import (
 	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
 	dcrdSecp256k1 "github.com/decred/dcrd/dcrec/secp256k1"
 	"github.com/pkg/errors"
-	"github.com/skip-mev/block-sdk/v2/testutils"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const (
 	defaultGasAdjustment = 1.5
 	defaultGasLimit      = 300000
+	maxSequenceRetries   = 3
 )
 
 type CosmosClient struct {
@@ -42,8 +48,18 @@ type CosmosClient struct {
 	privateKey          secp256k1.PrivKey
 	publicKey           cryptotypes.PubKey
 	account             authtypes.BaseAccount
+	accountMu           sync.RWMutex
 	accAddress          cosmostypes.AccAddress
 	chainID             string
+	proofVerifier       *ProofVerifier
+	encodingConfig      EncodingConfig
+	signMode            signing.SignMode
+	feeOptions          FeeOptions
+	sequence            *SequenceManager
+	broadcastMu         sync.Mutex
+	txOptions           TxOptions
+	txEvents            *txEventSubscription
+	eventClientMu       sync.RWMutex
 }
 
 type ValidatorPubInfo struct {
@@ -54,9 +70,9 @@ type ValidatorPubInfo struct {
 	Address      string
 }
 
-func (c *CosmosClient) GetValidatorDescription(val string) (*stakingv1beta1.Description, error) {
+func (c *CosmosClient) GetValidatorDescription(ctx context.Context, val string) (*stakingv1beta1.Description, error) {
 	resp, err := c.stakingQueryClient.Validator(
-		context.Background(),
+		ctx,
 		&stakingv1beta1.QueryValidatorRequest{ValidatorAddr: val},
 	)
 	if err != nil {
@@ -65,10 +81,10 @@ func (c *CosmosClient) GetValidatorDescription(val string) (*stakingv1beta1.Desc
 	return resp.Validator.Description, nil
 }
 
-func (c *CosmosClient) GetAuthorizedAddrMap(keyIsValidator bool) (map[string]string, error) {
+func (c *CosmosClient) GetAuthorizedAddrMap(ctx context.Context, keyIsValidator bool) (map[string]string, error) {
 	authorizedAddrMap := make(map[string]string)
 	allAuthorizedAddr, err := c.keyshareQueryClient.AuthorizedAddressAll(
-		context.Background(),
+		ctx,
 		&keyshare.QueryAuthorizedAddressAllRequest{},
 	)
 	if err != nil {
@@ -89,23 +105,31 @@ func (c *CosmosClient) GetAuthorizedAddrMap(keyIsValidator bool) (map[string]str
 	return authorizedAddrMap, nil
 }
 
-func (c *CosmosClient) GetCurrentPubKeyValidatorsInfo() ([]ValidatorPubInfo, error) {
-	pubKeyResp, err := c.keyshareQueryClient.Pubkey(context.Background(), &keyshare.QueryPubkeyRequest{})
+func (c *CosmosClient) GetCurrentPubKeyValidatorsInfo(ctx context.Context) ([]ValidatorPubInfo, error) {
+	holders, _, err := c.GetCurrentPubKeyHolders(ctx)
+	return holders, err
+}
+
+// GetCurrentPubKeyHolders returns every active pub key share holder & the number of validators the active pub key
+// was generated for, failing if any holder can not be resolved rather than leaving it out
+func (c *CosmosClient) GetCurrentPubKeyHolders(ctx context.Context) ([]ValidatorPubInfo, uint64, error) {
+	pubKeyResp, err := c.keyshareQueryClient.Pubkey(ctx, &keyshare.QueryPubkeyRequest{})
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	if pubKeyResp.ActivePubkey == nil {
-		return []ValidatorPubInfo{}, nil
+		return []ValidatorPubInfo{}, 0, nil
 	}
 
+	numberOfValidators := pubKeyResp.ActivePubkey.NumberOfValidators
 	if len(pubKeyResp.ActivePubkey.EncryptedKeyshares) == 0 {
-		return []ValidatorPubInfo{}, nil
+		return []ValidatorPubInfo{}, numberOfValidators, nil
 	}
 
-	authAddrMap, err := c.GetAuthorizedAddrMap(false)
+	authAddrMap, err := c.GetAuthorizedAddrMap(ctx, false)
 	if err != nil {
-		return nil, errors.Wrap(err, "error when getting all authorized addresses")
+		return nil, 0, errors.Wrap(err, "error when getting all authorized addresses")
 	}
 
 	validatorPubKeys := make([]ValidatorPubInfo, 0)
@@ -123,42 +147,36 @@ func (c *CosmosClient) GetCurrentPubKeyValidatorsInfo() ([]ValidatorPubInfo, err
 			targetAddr = eks.Validator
 		}
 
-		resp, err := c.authClient.Account(
-			context.Background(),
-			&authtypes.QueryAccountRequest{Address: targetAddr},
-		)
+		account, err := c.GetAccount(ctx, targetAddr)
 		if err != nil {
-			return nil, errors.Wrap(err, "error when querying account info")
+			return nil, 0, errors.Wrapf(err, "error when querying holder %s account info", targetAddr)
 		}
 
-		var baseAccount authtypes.BaseAccount
-
-		if err = baseAccount.Unmarshal(resp.Account.Value); err != nil {
-			return nil, errors.Wrap(err, "error when unmarshalling base account")
-		}
-
-		if baseAccount.PubKey == nil {
-			log.Printf("Skip Validator: %s due to pubkey not found\n", targetAddr)
-			continue
+		secp256k1PubKey, err := accountSecp256k1PubKey(account)
+		if err != nil {
+			return nil, 0, errors.Wrapf(err, "error when getting holder %s account pub key", targetAddr)
 		}
 
-		var secp256k1PubKey secp256k1.PubKey
-		if err = secp256k1PubKey.Unmarshal(baseAccount.PubKey.Value); err != nil {
-			return nil, errors.Wrap(err, "error when unmarshalling pub key")
+		if secp256k1PubKey == nil {
+			return nil, 0, errors.Errorf("holder %s account has no pub key", targetAddr)
 		}
 		pubKey, err := dcrdSecp256k1.ParsePubKey(secp256k1PubKey.Key)
 		if err != nil {
-			return nil, errors.Wrap(err, "error parsing pub key to dcrd pub key")
+			return nil, 0, errors.Wrap(err, "error parsing pub key to dcrd pub key")
 		}
 
-		validatorDescription, err := c.GetValidatorDescription(cosmostypes.ValAddress(secp256k1PubKey.Address()).String())
+		validatorDescription, err := c.GetValidatorDescription(ctx, cosmostypes.ValAddress(secp256k1PubKey.Address()).String())
+		if status.Code(err) == codes.NotFound {
+			// Validator left the staking module but still holds a share of the active pub key
+			log.Printf("Validator %s not found, holding the active pub key share without description\n", targetAddr)
+			validatorDescription, err = &stakingv1beta1.Description{}, nil
+		}
 		if err != nil {
-			log.Printf("error getting validator description: %s\n", err)
-			continue
+			return nil, 0, errors.Wrapf(err, "error getting holder %s validator description", targetAddr)
 		}
 		info := ValidatorPubInfo{
 			PublicKey:   pubKey,
-			Address:     baseAccount.Address,
+			Address:     account.GetAddress().String(),
 			Description: validatorDescription,
 		}
 
@@ -169,12 +187,12 @@ func (c *CosmosClient) GetCurrentPubKeyValidatorsInfo() ([]ValidatorPubInfo, err
 		validatorPubKeys = append(validatorPubKeys, info)
 	}
 
-	return validatorPubKeys, nil
+	return validatorPubKeys, numberOfValidators, nil
 }
 
-func (c *CosmosClient) GetAllValidatorsPubInfos() ([]ValidatorPubInfo, error) {
+func (c *CosmosClient) GetAllValidatorsPubInfos(ctx context.Context) ([]ValidatorPubInfo, error) {
 	validatorsResp, err := c.keyshareQueryClient.ValidatorSetAll(
-		context.Background(),
+		ctx,
 		&keyshare.QueryValidatorSetAllRequest{},
 	)
 
@@ -188,7 +206,7 @@ func (c *CosmosClient) GetAllValidatorsPubInfos() ([]ValidatorPubInfo, error) {
 
 	validatorPubKeys := make([]ValidatorPubInfo, 0)
 
-	authAddrMap, err := c.GetAuthorizedAddrMap(true)
+	authAddrMap, err := c.GetAuthorizedAddrMap(ctx, true)
 	if err != nil {
 		return nil, errors.Wrap(err, "error when getting all authorized addresses")
 	}
@@ -201,48 +219,61 @@ func (c *CosmosClient) GetAllValidatorsPubInfos() ([]ValidatorPubInfo, error) {
 			targetAddr = authorizedTo
 		}
 
-		resp, err := c.authClient.Account(
-			context.Background(),
-			&authtypes.QueryAccountRequest{Address: targetAddr},
-		)
+		if c.proofVerifier != nil {
+			if err = c.proofVerifier.VerifyValidatorSet(ctx, addr.Index, addr.Validator, addr.IsActive); err != nil {
+				return nil, errors.Wrap(err, "error verifying validator set proof")
+			}
+			if found {
+				if err = c.proofVerifier.VerifyAuthorizedAddress(ctx, authorizedTo, addr.Validator); err != nil {
+					return nil, errors.Wrap(err, "error verifying authorized address proof")
+				}
+			}
+		}
+
+		account, err := c.GetAccount(ctx, targetAddr)
 		if err != nil {
 			return nil, errors.Wrap(err, "error when querying account info")
 		}
 
-		var baseAccount authtypes.BaseAccount
-
-		if err = baseAccount.Unmarshal(resp.Account.Value); err != nil {
-			return nil, errors.Wrap(err, "error when unmarshalling base account")
+		secp256k1PubKey, err := accountSecp256k1PubKey(account)
+		if err == nil && secp256k1PubKey == nil {
+			err = errors.New("pubkey not found")
 		}
-
-		if baseAccount.PubKey == nil {
-			log.Printf("Skip Validator: %s due to pubkey not found\n", targetAddr)
+		if err != nil {
+			// The node could hide the pub key to leave the validator out, the skip is proven first
+			if c.proofVerifier != nil {
+				if proofErr := c.proofVerifier.VerifyAccountWithoutPubKey(ctx, targetAddr); proofErr != nil {
+					return nil, errors.Wrapf(proofErr, "error verifying skipped validator %s", targetAddr)
+				}
+			}
+			log.Printf("Skip Validator: %s due to %s\n", targetAddr, err.Error())
 			continue
 		}
-
-		var secp256k1PubKey secp256k1.PubKey
-		if err = secp256k1PubKey.Unmarshal(baseAccount.PubKey.Value); err != nil {
-			return nil, errors.Wrap(err, "error when unmarshalling pub key")
-		}
 		pubKey, err := dcrdSecp256k1.ParsePubKey(secp256k1PubKey.Key)
 		if err != nil {
 			return nil, errors.Wrap(err, "error parsing pub key to dcrd pub key")
 		}
 
+		if c.proofVerifier != nil {
+			if err = c.proofVerifier.VerifyAccountPubKey(ctx, targetAddr, secp256k1PubKey.Key); err != nil {
+				return nil, errors.Wrap(err, "error verifying account proof")
+			}
+		}
+
 		if !found {
-			validatorDescription, err := c.GetValidatorDescription(cosmostypes.ValAddress(secp256k1PubKey.Address()).String())
+			validatorDescription, err := c.GetValidatorDescription(ctx, cosmostypes.ValAddress(secp256k1PubKey.Address()).String())
 			if err != nil {
 				return nil, errors.Wrap(err, "error getting validator description")
 			}
 			validatorPubKeys = append(validatorPubKeys, ValidatorPubInfo{
 				PublicKey:   pubKey,
-				Address:     baseAccount.Address,
+				Address:     account.GetAddress().String(),
 				Description: validatorDescription,
 			})
 		} else {
 			validatorPubKeys = append(validatorPubKeys, ValidatorPubInfo{
 				PublicKey:    pubKey,
-				Address:      baseAccount.Address,
+				Address:      account.GetAddress().String(),
 				Description:  nil,
 				AuthorizedBy: addr.Validator,
 			})
@@ -252,6 +283,7 @@ func (c *CosmosClient) GetAllValidatorsPubInfos() ([]ValidatorPubInfo, error) {
 }
 
 func NewCosmosClient(
+	ctx context.Context,
 	endpoint string,
 	privateKeyHex string,
 	chainID string,
@@ -279,32 +311,11 @@ func NewCosmosClient(
 	pubKey := privateKey.PubKey()
 	address := pubKey.Address()
 
-	cfg := cosmostypes.GetConfig()
-	cfg.SetBech32PrefixForAccount("fairy", "fairypub")
-	cfg.SetBech32PrefixForValidator("fairyvaloper", "fairyvaloperpub")
-	cfg.SetBech32PrefixForConsensusNode("fairyvalcons", "fairyrvalconspub")
+	setBech32Prefixes()
 
 	accAddr := cosmostypes.AccAddress(address)
-	addr := accAddr.String()
 
-	var baseAccount authtypes.BaseAccount
-
-	resp, err := authClient.Account(
-		context.Background(),
-		&authtypes.QueryAccountRequest{Address: addr},
-	)
-
-	if err != nil {
-		log.Println(cosmostypes.AccAddress(address).String())
-		return nil, err
-	}
-
-	err = baseAccount.Unmarshal(resp.Account.Value)
-	if err != nil {
-		return nil, err
-	}
-
-	return &CosmosClient{
+	client := CosmosClient{
 		bankQueryClient:     bankClient,
 		authClient:          authClient,
 		txClient:            tx.NewServiceClient(grpcConn),
@@ -313,38 +324,97 @@ func NewCosmosClient(
 		stakingQueryClient:  stakingQueryClient,
 		grpcConn:            grpcConn,
 		privateKey:          privateKey,
-		account:             baseAccount,
 		accAddress:          accAddr,
 		publicKey:           pubKey,
 		chainID:             chainID,
-	}, nil
+		encodingConfig:      MakeEncodingConfig(),
+		signMode:            signing.SignMode_SIGN_MODE_DIRECT,
+		sequence:            NewSequenceManager(0),
+	}
+
+	if err = client.UpdateClientAccountInfo(ctx); err != nil {
+		log.Println(accAddr.String())
+		return nil, err
+	}
+
+	return &client, nil
+}
+
+func setBech32Prefixes() {
+	cfg := cosmostypes.GetConfig()
+	cfg.SetBech32PrefixForAccount("fairy", "fairypub")
+	cfg.SetBech32PrefixForValidator("fairyvaloper", "fairyvaloperpub")
+	cfg.SetBech32PrefixForConsensusNode("fairyvalcons", "fairyrvalconspub")
+}
+
+// Close closes the gRPC connection to the node, if any
+func (c *CosmosClient) Close() error {
+	if c.grpcConn == nil {
+		return nil
+	}
+	return c.grpcConn.Close()
 }
 
-func (c *CosmosClient) UpdateClientAccountInfo() error {
-	var baseAccount authtypes.BaseAccount
+// SetProofVerifier makes every validator set, authorization & account pub key
+// returned by GetAllValidatorsPubInfos verified with the given verifier
+func (c *CosmosClient) SetProofVerifier(verifier *ProofVerifier) {
+	c.proofVerifier = verifier
+}
 
+// GetAccount returns the account of the given address, whatever its type is
+func (c *CosmosClient) GetAccount(ctx context.Context, address string) (cosmostypes.AccountI, error) {
 	resp, err := c.authClient.Account(
-		context.Background(),
-		&authtypes.QueryAccountRequest{Address: c.GetAddress()},
+		ctx,
+		&authtypes.QueryAccountRequest{Address: address},
 	)
-
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return unpackAccount(c.encodingConfig.Codec, resp.Account)
+}
 
-	err = baseAccount.Unmarshal(resp.Account.Value)
+// SetFeeOptions sets how the fee of every tx is computed, no fee is paid by default
+func (c *CosmosClient) SetFeeOptions(opts FeeOptions) {
+	c.feeOptions = opts
+}
+
+// SetSignMode sets the sign mode used for signing every tx, SIGN_MODE_DIRECT by default
+func (c *CosmosClient) SetSignMode(signMode signing.SignMode) {
+	c.signMode = signMode
+}
+
+func (c *CosmosClient) UpdateClientAccountInfo(ctx context.Context) error {
+	account, err := c.GetAccount(ctx, c.accAddress.String())
 	if err != nil {
 		return err
 	}
 
-	c.account = baseAccount
+	c.accountMu.Lock()
+	c.account = authtypes.BaseAccount{
+		Address:       account.GetAddress().String(),
+		AccountNumber: account.GetAccountNumber(),
+		Sequence:      account.GetSequence(),
+	}
+	c.accountMu.Unlock()
+
+	// Not synced while a tx is being signed & broadcast, its sequence is not recorded yet
+	c.broadcastMu.Lock()
+	c.sequence.Sync(account.GetSequence())
+	c.broadcastMu.Unlock()
 
 	return nil
 }
 
-func (c *CosmosClient) GetActivePubKey() (*types.QueryPubkeyResponse, error) {
+// getAccount returns a copy of the account info, it is updated concurrently by UpdateClientAccountInfo
+func (c *CosmosClient) getAccount() authtypes.BaseAccount {
+	c.accountMu.RLock()
+	defer c.accountMu.RUnlock()
+	return c.account
+}
+
+func (c *CosmosClient) GetActivePubKey(ctx context.Context) (*types.QueryPubkeyResponse, error) {
 	resp, err := c.pepQueryClient.Pubkey(
-		context.Background(),
+		ctx,
 		&types.QueryPubkeyRequest{},
 	)
 	if err != nil {
@@ -353,9 +423,9 @@ func (c *CosmosClient) GetActivePubKey() (*types.QueryPubkeyResponse, error) {
 	return resp, nil
 }
 
-func (c *CosmosClient) GetLatestHeight() (uint64, error) {
+func (c *CosmosClient) GetLatestHeight(ctx context.Context) (uint64, error) {
 	resp, err := c.pepQueryClient.LatestHeight(
-		context.Background(),
+		ctx,
 		&types.QueryLatestHeightRequest{},
 	)
 	if err != nil {
@@ -364,9 +434,9 @@ func (c *CosmosClient) GetLatestHeight() (uint64, error) {
 	return resp.Height, nil
 }
 
-func (c *CosmosClient) GetBalance(denom string) (*math.Int, error) {
+func (c *CosmosClient) GetBalance(ctx context.Context, denom string) (*math.Int, error) {
 	resp, err := c.bankQueryClient.Balance(
-		context.Background(),
+		ctx,
 		&banktypes.QueryBalanceRequest{
 			Address: c.GetAddress(),
 			Denom:   denom,
@@ -378,8 +448,8 @@ func (c *CosmosClient) GetBalance(denom string) (*math.Int, error) {
 	return &resp.Balance.Amount, nil
 }
 
-func (c *CosmosClient) SendToken(target, denom string, amount math.Int, adjustGas bool) (*cosmostypes.TxResponse, error) {
-	resp, err := c.BroadcastTx(&banktypes.MsgSend{
+func (c *CosmosClient) SendToken(ctx context.Context, target, denom string, amount math.Int, adjustGas bool) (*cosmostypes.TxResponse, error) {
+	resp, err := c.BroadcastTx(ctx, &banktypes.MsgSend{
 		FromAddress: c.GetAddress(),
 		ToAddress:   target,
 		Amount:      cosmostypes.NewCoins(cosmostypes.NewCoin(denom, amount)),
@@ -387,12 +457,12 @@ func (c *CosmosClient) SendToken(target, denom string, amount math.Int, adjustGa
 	return resp, err
 }
 
-func (c *CosmosClient) MultiSend(denom string, totalAmount, eachAmt math.Int, targets []cosmostypes.AccAddress, adjustGas bool) (*cosmostypes.TxResponse, error) {
+func (c *CosmosClient) MultiSend(ctx context.Context, denom string, totalAmount, eachAmt math.Int, targets []cosmostypes.AccAddress, adjustGas bool) (*cosmostypes.TxResponse, error) {
 	outputs := make([]banktypes.Output, len(targets))
 	for i, each := range targets {
 		outputs[i] = banktypes.NewOutput(each, cosmostypes.NewCoins(cosmostypes.NewCoin(denom, eachAmt)))
 	}
-	resp, err := c.BroadcastTx(&banktypes.MsgMultiSend{
+	resp, err := c.BroadcastTx(ctx, &banktypes.MsgMultiSend{
 		Inputs:  []banktypes.Input{banktypes.NewInput(c.accAddress, cosmostypes.NewCoins(cosmostypes.NewCoin(denom, totalAmount)))},
 		Outputs: outputs,
 	}, adjustGas)
@@ -401,7 +471,7 @@ func (c *CosmosClient) MultiSend(denom string, totalAmount, eachAmt math.Int, ta
 }
 
 func (c *CosmosClient) GetAddress() string {
-	return c.account.Address
+	return c.getAccount().Address
 }
 
 func (c *CosmosClient) GetAccAddress() cosmostypes.AccAddress {
@@ -417,91 +487,285 @@ func (c *CosmosClient) handleBroadcastResult(resp *cosmostypes.TxResponse, err e
 	}
 
 	if resp.Code > 0 {
-		return errors.Errorf("error code: '%d' msg: '%s'", resp.Code, resp.RawLog)
+		return &TxRejectedError{Codespace: resp.Codespace, Code: resp.Code, RawLog: resp.RawLog}
 	}
 	return nil
 }
 
-func (c *CosmosClient) BroadcastTx(msg cosmostypes.Msg, adjustGas bool) (*cosmostypes.TxResponse, error) {
-	txBytes, err := c.signTxMsg(msg, adjustGas)
-	if err != nil {
-		return nil, err
-	}
+// TxRejectedError is returned when the node refuses to add the tx to its mempool
+type TxRejectedError struct {
+	Codespace string
+	Code      uint32
+	RawLog    string
+}
 
-	c.account.Sequence++
+func (e *TxRejectedError) Error() string {
+	return fmt.Sprintf("error code: '%d' msg: '%s'", e.Code, e.RawLog)
+}
 
-	resp, err := c.txClient.BroadcastTx(
-		context.Background(),
-		&tx.BroadcastTxRequest{
-			TxBytes: txBytes,
-			Mode:    tx.BroadcastMode_BROADCAST_MODE_SYNC,
-		},
-	)
-	if err != nil {
+// Temporary returns true if the tx may be accepted later without being changed
+func (e *TxRejectedError) Temporary() bool {
+	for _, err := range []*errorsmod.Error{sdkerrors.ErrMempoolIsFull, sdkerrors.ErrTxInMempoolCache} {
+		if e.Codespace == err.Codespace() && e.Code == err.ABCICode() {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CosmosClient) BroadcastTx(ctx context.Context, msg cosmostypes.Msg, adjustGas bool) (*cosmostypes.TxResponse, error) {
+	result, err := c.broadcast(ctx, msg, adjustGas, c.initialFeeOptions(ctx))
+	if result == nil {
 		return nil, err
 	}
+	if result.TxResponse == nil {
+		return nil, &InFlightTxError{
+			Hash:          result.Hash,
+			Sequence:      result.Sequence,
+			TimeoutHeight: result.TimeoutHeight,
+			Err:           errors.New("broadcast request failed, the node may have received the tx"),
+		}
+	}
+	return result.TxResponse, err
+}
 
-	return resp.TxResponse, c.handleBroadcastResult(resp.TxResponse, err)
+// initialFeeOptions returns the fee options to sign a new tx with,
+// with the gas price discovered from the node when auto fee is enabled
+func (c *CosmosClient) initialFeeOptions(ctx context.Context) FeeOptions {
+	feeOptions := c.feeOptions
+	if feeOptions.Auto {
+		feeOptions.GasPrice = c.DiscoverGasPrice(ctx)
+	}
+	return feeOptions
 }
 
-func (c *CosmosClient) WaitForTx(hash string, rate time.Duration) (*tx.GetTxResponse, error) {
+// broadcast signs & broadcasts the msg, retrying on sequence mismatch & insufficient fee.
+// Txs submitted concurrently are broadcast one at a time, so each one is signed with its own sequence
+func (c *CosmosClient) broadcast(ctx context.Context, msg cosmostypes.Msg, adjustGas bool, feeOptions FeeOptions) (*broadcastResult, error) {
+	c.broadcastMu.Lock()
+	defer c.broadcastMu.Unlock()
+
+	timeoutHeight, err := c.nextTimeoutHeight(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error computing tx timeout height")
+	}
+
+	var bumps, sequenceRetries uint64
 	for {
-		resp, err := c.txClient.GetTx(context.Background(), &tx.GetTxRequest{Hash: hash})
+		sequence := c.sequence.Next()
+
+		txBytes, fee, err := c.signTxMsg(ctx, msg, adjustGas, feeOptions, sequence, timeoutHeight)
 		if err != nil {
-			if strings.Contains(err.Error(), "not found") {
-				time.Sleep(rate)
-				continue
-			}
 			return nil, err
 		}
-		return resp, err
+
+		resp, err := c.txClient.BroadcastTx(
+			ctx,
+			&tx.BroadcastTxRequest{
+				TxBytes: txBytes,
+				Mode:    tx.BroadcastMode_BROADCAST_MODE_SYNC,
+			},
+		)
+		if err != nil {
+			if ctx.Err() != nil {
+				// Node may have received the tx before the request was cancelled
+				return nil, &InFlightTxError{
+					Hash:          txHash(txBytes),
+					Sequence:      sequence,
+					TimeoutHeight: timeoutHeight,
+					Err:           err,
+				}
+			}
+
+			// Node may have received the tx before the request failed, it is tracked until
+			// its timeout height rather than signing another one that could be included too
+			hash := txHash(txBytes)
+			log.Printf("Unable to broadcast tx %s, tracking it in case it was received: %s\n", hash, err.Error())
+			recordTxFee(feeOptions.GasPrice, fee)
+			return &broadcastResult{
+				Hash:          hash,
+				Sequence:      sequence,
+				TimeoutHeight: timeoutHeight,
+				FeeOptions:    feeOptions,
+			}, nil
+		}
+
+		if resp.TxResponse.Code == 0 {
+			c.sequence.Accepted(sequence, resp.TxResponse.TxHash)
+		}
+
+		if expected, mismatch := ParseSequenceMismatch(resp.TxResponse); mismatch && sequenceRetries < maxSequenceRetries {
+			sequenceRetries++
+			if err = c.resyncSequence(ctx, expected); err != nil {
+				return nil, errors.Wrap(err, "error resyncing account sequence")
+			}
+			log.Printf("Account sequence mismatch, got %d, retrying with %d (%d / %d)\n", sequence, c.sequence.Next(), sequenceRetries, maxSequenceRetries)
+			continue
+		}
+
+		if feeOptions.Auto && bumps < feeOptions.MaxBumps && isInsufficientFee(resp.TxResponse) {
+			bumps++
+			if feeOptions.GasPrice, err = c.bumpGasPrice(ctx, feeOptions); err != nil {
+				return nil, err
+			}
+			txFeeBumped.Inc()
+			log.Printf("Insufficient fee, retrying with gas price %s (%d / %d)\n", feeOptions.GasPrice, bumps, feeOptions.MaxBumps)
+			continue
+		}
+
+		recordTxFee(feeOptions.GasPrice, fee)
+
+		return &broadcastResult{
+			Hash:          resp.TxResponse.TxHash,
+			TxResponse:    resp.TxResponse,
+			Sequence:      sequence,
+			TimeoutHeight: timeoutHeight,
+			FeeOptions:    feeOptions,
+		}, c.handleBroadcastResult(resp.TxResponse, err)
 	}
 }
 
-func (c *CosmosClient) signTxMsg(msg cosmostypes.Msg, adjustGas bool) ([]byte, error) {
-	encodingCfg := testutils.CreateTestEncodingConfig()
-	txBuilder := encodingCfg.TxConfig.NewTxBuilder()
+// resyncSequence sets the next sequence to the one expected by the node,
+// or to the one committed on chain when the expected sequence is unknown
+func (c *CosmosClient) resyncSequence(ctx context.Context, expected uint64) error {
+	if expected > 0 {
+		c.sequence.Reset(expected)
+		return nil
+	}
 
-	err := txBuilder.SetMsgs(msg)
+	account, err := c.GetAccount(ctx, c.accAddress.String())
 	if err != nil {
-		return nil, err
+		return err
 	}
+	c.sequence.Reset(account.GetSequence())
+	return nil
+}
+
+// GetPendingTxs returns the hash of txs accepted to the mempool but not committed yet, by sequence
+func (c *CosmosClient) GetPendingTxs() map[uint64]string {
+	return c.sequence.Pending()
+}
+
+// pollTx waits for the tx to be included in a block by querying it at the given rate
+func (c *CosmosClient) pollTx(ctx context.Context, hash string, timeoutHeight uint64, rate time.Duration) (*tx.GetTxResponse, error) {
+	var height int64
+	for {
+		// Height is queried before the tx, so a tx included right at the
+		// timeout height can not be mistaken as expired
+		if timeoutHeight > 0 {
+			latestHeight, err := c.GetChainHeight(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				log.Printf("Unable to query chain height while tracking tx %s, retrying: %s\n", hash, err.Error())
+			} else {
+				height = latestHeight
+			}
+		}
+
+		resp, err := c.txClient.GetTx(ctx, &tx.GetTxRequest{Hash: hash})
+		if err == nil {
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		if !strings.Contains(err.Error(), "not found") {
+			// The tx may still be included, keep tracking it until the timeout height
+			log.Printf("Unable to query tx %s, retrying: %s\n", hash, err.Error())
+		} else if timeoutHeight > 0 && uint64(height) > timeoutHeight {
+			return nil, ErrTxExpired
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(rate):
+		}
+	}
+}
+
+// calculateGas simulates the msg and returns the gas limit adjusted with the default gas adjustment
+func (c *CosmosClient) calculateGas(msg cosmostypes.Msg, sequence uint64) (uint64, error) {
+	txf := clienttx.Factory{}.
+		WithGas(defaultGasLimit).
+		WithSignMode(c.signMode).
+		WithTxConfig(c.encodingConfig.TxConfig).
+		WithChainID(c.chainID).
+		WithAccountNumber(c.getAccount().AccountNumber).
+		WithSequence(sequence).
+		WithGasAdjustment(defaultGasAdjustment)
+
+	_, gasLimit, err := clienttx.CalculateGas(c.grpcConn, txf, msg)
+	return gasLimit, err
+}
 
+func (c *CosmosClient) signTxMsg(ctx context.Context, msg cosmostypes.Msg, adjustGas bool, feeOptions FeeOptions, sequence, timeoutHeight uint64) ([]byte, cosmostypes.Coins, error) {
 	var newGasLimit uint64 = defaultGasLimit
 	if adjustGas {
-		txf := clienttx.Factory{}.
-			WithGas(defaultGasLimit).
-			WithSignMode(1).
-			WithTxConfig(encodingCfg.TxConfig).
-			WithChainID(c.chainID).
-			WithAccountNumber(c.account.AccountNumber).
-			WithSequence(c.account.Sequence).
-			WithGasAdjustment(defaultGasAdjustment)
-
-		_, newGasLimit, err = clienttx.CalculateGas(c.grpcConn, txf, msg)
+		var err error
+		newGasLimit, err = c.calculateGas(msg, sequence)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
-	txBuilder.SetGasLimit(newGasLimit)
+	fee, err := feeOptions.ComputeFee(newGasLimit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	txBytes, err := c.buildSignedTx(ctx, msg, newGasLimit, fee, feeOptions.Granter, sequence, timeoutHeight)
+	if err != nil {
+		return nil, nil, err
+	}
+	return txBytes, fee, nil
+}
+
+// buildSignedTx signs the msg with the given gas limit & fee, without querying the node
+func (c *CosmosClient) buildSignedTx(
+	ctx context.Context,
+	msg cosmostypes.Msg,
+	gasLimit uint64,
+	fee cosmostypes.Coins,
+	granter cosmostypes.AccAddress,
+	sequence, timeoutHeight uint64,
+) ([]byte, error) {
+	txConfig := c.encodingConfig.TxConfig
+	txBuilder := txConfig.NewTxBuilder()
 
+	err := txBuilder.SetMsgs(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	txBuilder.SetGasLimit(gasLimit)
+	txBuilder.SetTimeoutHeight(timeoutHeight)
+	txBuilder.SetFeeAmount(fee)
+
+	if !granter.Empty() {
+		txBuilder.SetFeeGranter(granter)
+	}
+
+	account := c.getAccount()
 	signerData := authsigning.SignerData{
 		ChainID:       c.chainID,
-		AccountNumber: c.account.AccountNumber,
-		Sequence:      c.account.Sequence,
+		AccountNumber: account.AccountNumber,
+		Sequence:      sequence,
 		PubKey:        c.publicKey,
-		Address:       c.account.Address,
+		Address:       account.Address,
 	}
 
 	sigData := signing.SingleSignatureData{
-		SignMode:  1,
+		SignMode:  c.signMode,
 		Signature: nil,
 	}
 	sig := signing.SignatureV2{
 		PubKey:   c.publicKey,
 		Data:     &sigData,
-		Sequence: c.account.Sequence,
+		Sequence: sequence,
 	}
 
 	if err := txBuilder.SetSignatures(sig); err != nil {
@@ -509,19 +773,17 @@ func (c *CosmosClient) signTxMsg(msg cosmostypes.Msg, adjustGas bool) ([]byte, e
 	}
 
 	sigV2, err := clienttx.SignWithPrivKey(
-		context.Background(), 1, signerData, txBuilder, &c.privateKey,
-		encodingCfg.TxConfig, c.account.Sequence,
+		ctx, c.signMode, signerData, txBuilder, &c.privateKey,
+		txConfig, sequence,
 	)
-
-	err = txBuilder.SetSignatures(sigV2)
 	if err != nil {
 		return nil, err
 	}
 
-	txBytes, err := encodingCfg.TxConfig.TxEncoder()(txBuilder.GetTx())
+	err = txBuilder.SetSignatures(sigV2)
 	if err != nil {
 		return nil, err
 	}
 
-	return txBytes, nil
+	return txConfig.TxEncoder()(txBuilder.GetTx())
 }
@@ -0,0 +1,108 @@
+package cosmosClient
+
+import (
+	"cosmossdk.io/x/tx/signing"
+	keysharetypes "github.com/Fairblock/fairyring/x/keyshare/types"
+	peptypes "github.com/Fairblock/fairyring/x/pep/types"
+	"github.com/cosmos/cosmos-sdk/client"
+	"github.com/cosmos/cosmos-sdk/codec"
+	addresscodec "github.com/cosmos/cosmos-sdk/codec/address"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	cryptocodec "github.com/cosmos/cosmos-sdk/crypto/codec"
+	"github.com/cosmos/cosmos-sdk/crypto/keys/secp256k1"
+	cosmostypes "github.com/cosmos/cosmos-sdk/types"
+	signingtypes "github.com/cosmos/cosmos-sdk/types/tx/signing"
+	authtx "github.com/cosmos/cosmos-sdk/x/auth/tx"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	vestingtypes "github.com/cosmos/cosmos-sdk/x/auth/vesting/types"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	"github.com/cosmos/gogoproto/proto"
+	"github.com/pkg/errors"
+)
+
+const (
+	Bech32PrefixAccAddr = "fairy"
+	Bech32PrefixValAddr = "fairyvaloper"
+
+	SignModeDirect    = "direct"
+	SignModeAminoJSON = "amino-json"
+)
+
+type EncodingConfig struct {
+	InterfaceRegistry codectypes.InterfaceRegistry
+	Codec             codec.Codec
+	TxConfig          client.TxConfig
+}
+
+// MakeEncodingConfig returns the encoding config of FairyRing,
+// with the auth, vesting, bank, keyshare & pep interfaces registered
+func MakeEncodingConfig() EncodingConfig {
+	interfaceRegistry, err := codectypes.NewInterfaceRegistryWithOptions(codectypes.InterfaceRegistryOptions{
+		ProtoFiles: proto.HybridResolver,
+		SigningOptions: signing.Options{
+			AddressCodec:          addresscodec.NewBech32Codec(Bech32PrefixAccAddr),
+			ValidatorAddressCodec: addresscodec.NewBech32Codec(Bech32PrefixValAddr),
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	cryptocodec.RegisterInterfaces(interfaceRegistry)
+	authtypes.RegisterInterfaces(interfaceRegistry)
+	vestingtypes.RegisterInterfaces(interfaceRegistry)
+	banktypes.RegisterInterfaces(interfaceRegistry)
+	keysharetypes.RegisterInterfaces(interfaceRegistry)
+	peptypes.RegisterInterfaces(interfaceRegistry)
+
+	protoCodec := codec.NewProtoCodec(interfaceRegistry)
+
+	return EncodingConfig{
+		InterfaceRegistry: interfaceRegistry,
+		Codec:             protoCodec,
+		TxConfig: authtx.NewTxConfig(protoCodec, []signingtypes.SignMode{
+			signingtypes.SignMode_SIGN_MODE_DIRECT,
+			signingtypes.SignMode_SIGN_MODE_LEGACY_AMINO_JSON,
+		}),
+	}
+}
+
+// ParseSignMode returns the sign mode of the given config value, empty value defaults to direct
+func ParseSignMode(signMode string) (signingtypes.SignMode, error) {
+	switch signMode {
+	case "", SignModeDirect:
+		return signingtypes.SignMode_SIGN_MODE_DIRECT, nil
+	case SignModeAminoJSON:
+		return signingtypes.SignMode_SIGN_MODE_LEGACY_AMINO_JSON, nil
+	default:
+		return signingtypes.SignMode_SIGN_MODE_UNSPECIFIED, errors.Errorf(
+			"unsupported sign mode: '%s', expected '%s' or '%s'", signMode, SignModeDirect, SignModeAminoJSON,
+		)
+	}
+}
+
+func unpackAccount(cdc codec.Codec, accountAny *codectypes.Any) (cosmostypes.AccountI, error) {
+	if accountAny == nil {
+		return nil, errors.New("account is empty")
+	}
+
+	var account cosmostypes.AccountI
+	if err := cdc.UnpackAny(accountAny, &account); err != nil {
+		return nil, errors.Wrapf(err, "error when unpacking account of type %s", accountAny.TypeUrl)
+	}
+	return account, nil
+}
+
+// accountSecp256k1PubKey returns the secp256k1 public key of the account, nil if the account does not have any
+func accountSecp256k1PubKey(account cosmostypes.AccountI) (*secp256k1.PubKey, error) {
+	pubKey := account.GetPubKey()
+	if pubKey == nil {
+		return nil, nil
+	}
+
+	secp256k1PubKey, ok := pubKey.(*secp256k1.PubKey)
+	if !ok {
+		return nil, errors.Errorf("unsupported account pub key type: %s", pubKey.Type())
+	}
+	return secp256k1PubKey, nil
+}
@@ -0,0 +1,209 @@
+package cosmosClient
+
+import (
+	"context"
+	"testing"
+
+	txsigning "cosmossdk.io/x/tx/signing"
+	keysharetypes "github.com/Fairblock/fairyring/x/keyshare/types"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	"github.com/cosmos/cosmos-sdk/crypto/keys/ed25519"
+	"github.com/cosmos/cosmos-sdk/crypto/keys/secp256k1"
+	cryptotypes "github.com/cosmos/cosmos-sdk/crypto/types"
+	cosmostypes "github.com/cosmos/cosmos-sdk/types"
+	signingtypes "github.com/cosmos/cosmos-sdk/types/tx/signing"
+	authsigning "github.com/cosmos/cosmos-sdk/x/auth/signing"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	vestingtypes "github.com/cosmos/cosmos-sdk/x/auth/vesting/types"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+func TestUnpackAccount(t *testing.T) {
+	setBech32Prefixes()
+	cfg := MakeEncodingConfig()
+
+	secpKey := secp256k1.GenPrivKey().PubKey()
+	edKey := ed25519.GenPrivKey().PubKey()
+
+	baseAccount := func(pubKey cryptotypes.PubKey) *authtypes.BaseAccount {
+		address := cosmostypes.AccAddress(secp256k1.GenPrivKey().PubKey().Address())
+		if pubKey != nil {
+			address = cosmostypes.AccAddress(pubKey.Address())
+		}
+		return authtypes.NewBaseAccount(address, pubKey, 4, 2)
+	}
+	originalVesting := cosmostypes.NewCoins(cosmostypes.NewInt64Coin("ufairy", 1000))
+
+	continuousVesting, err := vestingtypes.NewContinuousVestingAccount(baseAccount(secpKey), originalVesting, 100, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delayedVesting, err := vestingtypes.NewDelayedVestingAccount(baseAccount(secpKey), originalVesting, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	periodicVesting, err := vestingtypes.NewPeriodicVestingAccount(baseAccount(secpKey), originalVesting, 100, vestingtypes.Periods{
+		{Length: 50, Amount: cosmostypes.NewCoins(cosmostypes.NewInt64Coin("ufairy", 400))},
+		{Length: 50, Amount: cosmostypes.NewCoins(cosmostypes.NewInt64Coin("ufairy", 600))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	permanentLocked, err := vestingtypes.NewPermanentLockedAccount(baseAccount(secpKey), originalVesting)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		account    cosmostypes.AccountI
+		wantPubKey cryptotypes.PubKey
+		wantErr    bool
+	}{
+		{name: "base account", account: baseAccount(secpKey), wantPubKey: secpKey},
+		{name: "base account without pub key", account: baseAccount(nil)},
+		{name: "continuous vesting account", account: continuousVesting, wantPubKey: secpKey},
+		{name: "delayed vesting account", account: delayedVesting, wantPubKey: secpKey},
+		{name: "periodic vesting account", account: periodicVesting, wantPubKey: secpKey},
+		{name: "permanent locked account", account: permanentLocked, wantPubKey: secpKey},
+		{name: "module account without pub key", account: authtypes.NewEmptyModuleAccount("keyshare")},
+		{name: "ed25519 pub key", account: baseAccount(edKey), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accountAny, err := codectypes.NewAnyWithValue(tt.account)
+			if err != nil {
+				t.Fatalf("error packing account: %s", err)
+			}
+
+			account, err := unpackAccount(cfg.Codec, accountAny)
+			if err != nil {
+				t.Fatalf("unpackAccount() error = %v", err)
+			}
+			if !account.GetAddress().Equals(tt.account.GetAddress()) {
+				t.Fatalf("unpackAccount() address = %s, want %s", account.GetAddress(), tt.account.GetAddress())
+			}
+
+			pubKey, err := accountSecp256k1PubKey(account)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("accountSecp256k1PubKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if tt.wantPubKey == nil {
+				if pubKey != nil {
+					t.Fatalf("accountSecp256k1PubKey() = %s, want nil", pubKey)
+				}
+				return
+			}
+			if pubKey == nil || !pubKey.Equals(tt.wantPubKey) {
+				t.Fatalf("accountSecp256k1PubKey() = %v, want %s", pubKey, tt.wantPubKey)
+			}
+		})
+	}
+}
+
+func TestUnpackAccountEmpty(t *testing.T) {
+	if _, err := unpackAccount(MakeEncodingConfig().Codec, nil); err == nil {
+		t.Fatal("unpackAccount() of nil account should fail")
+	}
+}
+
+func TestSignModePubkeyMsgs(t *testing.T) {
+	validator := cosmostypes.AccAddress(secp256k1.GenPrivKey().PubKey().Address()).String()
+	encryptedKeyshares := []*keysharetypes.EncryptedKeyshare{{Data: "encrypted keyshare", Validator: validator}}
+
+	createMsg := func(creator string) cosmostypes.Msg {
+		return &keysharetypes.MsgCreateLatestPubkey{
+			Creator:            creator,
+			PublicKey:          "public key",
+			Commitments:        []string{"commitment"},
+			NumberOfValidators: 1,
+			EncryptedKeyshares: encryptedKeyshares,
+		}
+	}
+	overrideMsg := func(creator string) cosmostypes.Msg {
+		return &keysharetypes.MsgOverrideLatestPubkey{
+			Creator:            creator,
+			PublicKey:          "public key",
+			Commitments:        []string{"commitment"},
+			NumberOfValidators: 1,
+			EncryptedKeyshares: encryptedKeyshares,
+		}
+	}
+
+	tests := []struct {
+		name     string
+		msg      func(creator string) cosmostypes.Msg
+		signMode signingtypes.SignMode
+	}{
+		{name: "create latest pub key direct", msg: createMsg, signMode: signingtypes.SignMode_SIGN_MODE_DIRECT},
+		{name: "create latest pub key amino-json", msg: createMsg, signMode: signingtypes.SignMode_SIGN_MODE_LEGACY_AMINO_JSON},
+		{name: "override latest pub key direct", msg: overrideMsg, signMode: signingtypes.SignMode_SIGN_MODE_DIRECT},
+		{name: "override latest pub key amino-json", msg: overrideMsg, signMode: signingtypes.SignMode_SIGN_MODE_LEGACY_AMINO_JSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestOfflineClient(t)
+			c.SetSignMode(tt.signMode)
+
+			msg := tt.msg(c.GetAddress())
+			params := SignParams{
+				ChainID:       "fairyring-test",
+				AccountNumber: 3,
+				Sequence:      7,
+				GasLimit:      200000,
+				Fee:           "5000ufair",
+				TimeoutHeight: 120,
+			}
+			txBytes, _, err := c.SignOffline(context.Background(), msg, params)
+			if err != nil {
+				t.Fatalf("SignOffline() error = %v", err)
+			}
+
+			txConfig := c.encodingConfig.TxConfig
+			decoded, err := txConfig.TxDecoder()(txBytes)
+			if err != nil {
+				t.Fatalf("error decoding tx: %s", err)
+			}
+			sigTx := decoded.(authsigning.SigVerifiableTx)
+			sigs, err := sigTx.GetSignaturesV2()
+			if err != nil || len(sigs) != 1 {
+				t.Fatalf("expected 1 signature, got %d, err: %v", len(sigs), err)
+			}
+			if got := sigs[0].Data.(*signingtypes.SingleSignatureData).SignMode; got != tt.signMode {
+				t.Fatalf("signature sign mode = %s, want %s", got, tt.signMode)
+			}
+
+			signerData := txsigning.SignerData{
+				ChainID:       params.ChainID,
+				AccountNumber: params.AccountNumber,
+				Sequence:      params.Sequence,
+				Address:       c.GetAddress(),
+				PubKey: &anypb.Any{
+					TypeUrl: codectypes.MsgTypeURL(c.publicKey),
+					Value:   c.publicKey.Bytes(),
+				},
+			}
+			txData := decoded.(authsigning.V2AdaptableTx).GetSigningTxData()
+			err = authsigning.VerifySignature(
+				context.Background(), c.publicKey, signerData, sigs[0].Data, txConfig.SignModeHandler(), txData,
+			)
+			if err != nil {
+				t.Fatalf("VerifySignature() error = %v", err)
+			}
+
+			// The signature must not verify on another chain
+			signerData.ChainID = "other-chain"
+			err = authsigning.VerifySignature(
+				context.Background(), c.publicKey, signerData, sigs[0].Data, txConfig.SignModeHandler(), txData,
+			)
+			if err == nil {
+				t.Fatal("VerifySignature() succeeded with a wrong chain id")
+			}
+		})
+	}
+}
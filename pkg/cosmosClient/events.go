@@ -0,0 +1,277 @@
+package cosmosClient
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	keysharetypes "github.com/Fairblock/fairyring/x/keyshare/types"
+	abcitypes "github.com/cometbft/cometbft/abci/types"
+	coretypes "github.com/cometbft/cometbft/rpc/core/types"
+	tmtypes "github.com/cometbft/cometbft/types"
+	cosmostypes "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/tx"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	TxConfirmedByEvent   = "event"
+	TxConfirmedByPolling = "polling"
+
+	SubscribeTimeout = 10 * time.Second
+)
+
+var txConfirmations = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "sharegenerationclient_tx_confirmations",
+	Help: "The total number of submitted txs confirmed, by websocket event or by polling",
+}, []string{"method"})
+
+// PubkeyEvent is a queued-pubkey-created or pubkey-overrode event emitted by the keyshare module
+type PubkeyEvent struct {
+	Type                     string
+	Pubkey                   string
+	Creator                  string
+	NumberOfValidators       uint64
+	ExpiryHeight             uint64
+	ActivePubkeyExpiryHeight uint64
+}
+
+// EventSubscriber is the started websocket client the tx events are subscribed with
+type EventSubscriber interface {
+	Subscribe(ctx context.Context, subscriber, query string, outCapacity ...int) (<-chan coretypes.ResultEvent, error)
+}
+
+// txEventSubscription forwards the events of the txs signed by the client to the txs waiting for them.
+// A single subscription is shared by every tx, so it takes one slot of the node max_subscriptions_per_client
+type txEventSubscription struct {
+	mu      sync.Mutex
+	waiters map[string][]chan tmtypes.EventDataTx
+	stop    chan struct{}
+	closed  chan struct{}
+}
+
+func newTxEventSubscription(out <-chan coretypes.ResultEvent) *txEventSubscription {
+	s := txEventSubscription{
+		waiters: make(map[string][]chan tmtypes.EventDataTx),
+		stop:    make(chan struct{}),
+		closed:  make(chan struct{}),
+	}
+	go s.run(out)
+	return &s
+}
+
+func (s *txEventSubscription) run(out <-chan coretypes.ResultEvent) {
+	defer close(s.closed)
+	for {
+		select {
+		case result, ok := <-out:
+			if !ok {
+				return
+			}
+			data, ok := result.Data.(tmtypes.EventDataTx)
+			if !ok {
+				continue
+			}
+			s.mu.Lock()
+			for _, waiter := range s.waiters[txHash(data.Tx)] {
+				select {
+				case waiter <- data:
+				default:
+				}
+			}
+			s.mu.Unlock()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// wait returns the channel the tx event is sent to, done must be called once the tx is no longer waited for
+func (s *txEventSubscription) wait(hash string) (events <-chan tmtypes.EventDataTx, done func()) {
+	hash = strings.ToUpper(hash)
+	waiter := make(chan tmtypes.EventDataTx, 1)
+
+	s.mu.Lock()
+	s.waiters[hash] = append(s.waiters[hash], waiter)
+	s.mu.Unlock()
+
+	return waiter, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		waiters := s.waiters[hash]
+		for i := range waiters {
+			if waiters[i] == waiter {
+				waiters = append(waiters[:i], waiters[i+1:]...)
+				break
+			}
+		}
+		if len(waiters) == 0 {
+			delete(s.waiters, hash)
+		} else {
+			s.waiters[hash] = waiters
+		}
+	}
+}
+
+func (s *txEventSubscription) isClosed() bool {
+	select {
+	case <-s.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// SetEventClient subscribes once to the events of the txs signed by the client over the given websocket client,
+// WaitForTx then waits for the tx event matching its hash. Txs are polled while no subscription is set or it failed
+func (c *CosmosClient) SetEventClient(ctx context.Context, client EventSubscriber) error {
+	c.ClearEventClient()
+
+	// Ante handler events are kept for failed txs, unlike the message ones
+	query := fmt.Sprintf("tm.event = 'Tx' AND tx.acc_seq CONTAINS '%s/'", c.GetAddress())
+
+	subscribeCtx, cancel := context.WithTimeout(ctx, SubscribeTimeout)
+	out, err := client.Subscribe(subscribeCtx, "", query)
+	cancel()
+	if err != nil {
+		return errors.Wrap(err, "error subscribing to tx events")
+	}
+
+	c.eventClientMu.Lock()
+	defer c.eventClientMu.Unlock()
+	c.txEvents = newTxEventSubscription(out)
+	return nil
+}
+
+// ClearEventClient stops waiting for tx events, WaitForTx polls txs until an event client is set again
+func (c *CosmosClient) ClearEventClient() {
+	c.eventClientMu.Lock()
+	defer c.eventClientMu.Unlock()
+	if c.txEvents != nil {
+		close(c.txEvents.stop)
+		c.txEvents = nil
+	}
+}
+
+func (c *CosmosClient) getTxEvents() *txEventSubscription {
+	c.eventClientMu.RLock()
+	defer c.eventClientMu.RUnlock()
+	if c.txEvents == nil || c.txEvents.isClosed() {
+		return nil
+	}
+	return c.txEvents
+}
+
+// WaitForTx waits for the tx to be included in a block.
+// ErrTxExpired is returned once the chain passed the tx timeout height without including it,
+// a timeout height of 0 waits forever
+func (c *CosmosClient) WaitForTx(ctx context.Context, hash string, timeoutHeight uint64, rate time.Duration) (*tx.GetTxResponse, error) {
+	if txEvents := c.getTxEvents(); txEvents != nil {
+		resp, waited, err := c.waitForTxEvent(ctx, txEvents, hash, timeoutHeight, rate)
+		if waited {
+			if err == nil {
+				txConfirmations.WithLabelValues(TxConfirmedByEvent).Inc()
+			}
+			return resp, err
+		}
+		log.Printf("Unable to wait for tx %s event, polling instead: %s\n", hash, err.Error())
+	}
+
+	resp, err := c.pollTx(ctx, hash, timeoutHeight, rate)
+	if err == nil {
+		txConfirmations.WithLabelValues(TxConfirmedByPolling).Inc()
+	}
+	return resp, err
+}
+
+// waitForTxEvent waits for the tx event from the tx event subscription,
+// waited is false if the subscription was closed before the tx got confirmed
+func (c *CosmosClient) waitForTxEvent(ctx context.Context, txEvents *txEventSubscription, hash string, timeoutHeight uint64, rate time.Duration) (resp *tx.GetTxResponse, waited bool, err error) {
+	events, done := txEvents.wait(hash)
+	defer done()
+
+	// Tx might have been included before waiting for its event
+	if resp, err := c.txClient.GetTx(ctx, &tx.GetTxRequest{Hash: hash}); err == nil {
+		return resp, true, nil
+	}
+
+	// Chain height is only checked to detect the tx expiry
+	var expiryCheck <-chan time.Time
+	if timeoutHeight > 0 {
+		ticker := time.NewTicker(rate)
+		defer ticker.Stop()
+		expiryCheck = ticker.C
+	}
+
+	for {
+		select {
+		case data := <-events:
+			return txResponseFromEvent(data), true, nil
+		case <-txEvents.closed:
+			return nil, false, errors.New("tx event subscription closed")
+		case <-ctx.Done():
+			return nil, true, ctx.Err()
+		case <-expiryCheck:
+			height, err := c.GetChainHeight(ctx)
+			if err != nil {
+				log.Printf("Unable to get chain height while waiting for tx %s: %s\n", hash, err.Error())
+				continue
+			}
+			if uint64(height) <= timeoutHeight {
+				continue
+			}
+			// Event might have been dropped, make sure the tx is not included
+			if resp, err := c.txClient.GetTx(ctx, &tx.GetTxRequest{Hash: hash}); err == nil {
+				return resp, true, nil
+			}
+			return nil, true, ErrTxExpired
+		}
+	}
+}
+
+func txResponseFromEvent(data tmtypes.EventDataTx) *tx.GetTxResponse {
+	return &tx.GetTxResponse{
+		TxResponse: cosmostypes.NewResponseResultTx(&coretypes.ResultTx{
+			Hash:     tmtypes.Tx(data.Tx).Hash(),
+			Height:   data.Height,
+			Index:    data.Index,
+			TxResult: data.Result,
+			Tx:       data.Tx,
+		}, nil, ""),
+	}
+}
+
+// DecodePubkeyEvents returns the keyshare module pubkey events found in the tx events
+func DecodePubkeyEvents(events []abcitypes.Event) []PubkeyEvent {
+	pubkeyEvents := make([]PubkeyEvent, 0)
+	for _, e := range events {
+		if e.Type != keysharetypes.QueuedPubkeyCreatedEventType && e.Type != keysharetypes.PubkeyOverrodeEventType {
+			continue
+		}
+
+		// Both events share the same attribute keys
+		pubkeyEvent := PubkeyEvent{Type: e.Type}
+		for _, attr := range e.Attributes {
+			switch attr.Key {
+			case keysharetypes.QueuedPubkeyCreatedEventPubkey:
+				pubkeyEvent.Pubkey = attr.Value
+			case keysharetypes.QueuedPubkeyCreatedEventCreator:
+				pubkeyEvent.Creator = attr.Value
+			case keysharetypes.QueuedPubkeyCreatedEventNumberOfValidators:
+				pubkeyEvent.NumberOfValidators, _ = strconv.ParseUint(attr.Value, 10, 64)
+			case keysharetypes.QueuedPubkeyCreatedEventExpiryHeight:
+				pubkeyEvent.ExpiryHeight, _ = strconv.ParseUint(attr.Value, 10, 64)
+			case keysharetypes.QueuedPubkeyCreatedEventActivePubkeyExpiryHeight:
+				pubkeyEvent.ActivePubkeyExpiryHeight, _ = strconv.ParseUint(attr.Value, 10, 64)
+			}
+		}
+		pubkeyEvents = append(pubkeyEvents, pubkeyEvent)
+	}
+	return pubkeyEvents
+}
@@ -0,0 +1,112 @@
+package cosmosClient
+
+import (
+	"context"
+
+	"cosmossdk.io/math"
+	"github.com/cosmos/cosmos-sdk/client/grpc/node"
+	cosmostypes "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/gogoproto/proto"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const feeMarketGasPriceMethod = "/feemarket.feemarket.v1.Query/GasPrice"
+
+// feeMarketGasPriceRequest & feeMarketGasPriceResponse are the minimal definitions
+// of the fee market module GasPrice query, so the module is not required as a dependency
+type feeMarketGasPriceRequest struct {
+	Denom string
+}
+
+func (m *feeMarketGasPriceRequest) Reset()         { *m = feeMarketGasPriceRequest{} }
+func (m *feeMarketGasPriceRequest) String() string { return proto.CompactTextString(m) }
+func (*feeMarketGasPriceRequest) ProtoMessage()    {}
+
+func (m *feeMarketGasPriceRequest) Marshal() ([]byte, error) {
+	b := protowire.AppendTag(nil, 1, protowire.BytesType)
+	return protowire.AppendString(b, m.Denom), nil
+}
+
+func (m *feeMarketGasPriceRequest) Unmarshal([]byte) error {
+	return errors.New("unmarshalling fee market gas price request is not supported")
+}
+
+type feeMarketGasPriceResponse struct {
+	Price cosmostypes.DecCoin
+}
+
+func (m *feeMarketGasPriceResponse) Reset()         { *m = feeMarketGasPriceResponse{} }
+func (m *feeMarketGasPriceResponse) String() string { return m.Price.String() }
+func (*feeMarketGasPriceResponse) ProtoMessage()    {}
+
+func (m *feeMarketGasPriceResponse) Marshal() ([]byte, error) {
+	return nil, errors.New("marshalling fee market gas price response is not supported")
+}
+
+func (m *feeMarketGasPriceResponse) Unmarshal(b []byte) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if num == 1 && typ == protowire.BytesType {
+			price, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			if err := m.Price.Unmarshal(price); err != nil {
+				return err
+			}
+			b = b[n:]
+			continue
+		}
+
+		n = protowire.ConsumeFieldValue(num, typ, b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+// GetNodeMinGasPrice returns the minimum gas price of the given denom configured on the node
+func (c *CosmosClient) GetNodeMinGasPrice(ctx context.Context, denom string) (math.LegacyDec, error) {
+	resp, err := node.NewServiceClient(c.grpcConn).Config(ctx, &node.ConfigRequest{})
+	if err != nil {
+		return math.LegacyDec{}, err
+	}
+
+	if len(resp.MinimumGasPrice) == 0 {
+		return math.LegacyZeroDec(), nil
+	}
+
+	minGasPrices, err := cosmostypes.ParseDecCoins(resp.MinimumGasPrice)
+	if err != nil {
+		return math.LegacyDec{}, errors.Wrapf(err, "invalid node minimum gas price: '%s'", resp.MinimumGasPrice)
+	}
+	return minGasPrices.AmountOf(denom), nil
+}
+
+// GetFeeMarketGasPrice returns the current gas price of the fee market module,
+// found is false when the chain does not have the module
+func (c *CosmosClient) GetFeeMarketGasPrice(ctx context.Context, denom string) (price math.LegacyDec, found bool, err error) {
+	var resp feeMarketGasPriceResponse
+	err = c.grpcConn.Invoke(ctx, feeMarketGasPriceMethod, &feeMarketGasPriceRequest{Denom: denom}, &resp)
+	if err != nil {
+		if status.Code(err) == codes.Unimplemented {
+			return math.LegacyDec{}, false, nil
+		}
+		return math.LegacyDec{}, false, err
+	}
+
+	if resp.Price.Amount.IsNil() {
+		return math.LegacyZeroDec(), true, nil
+	}
+	return resp.Price.Amount, true, nil
+}
@@ -0,0 +1,109 @@
+package cosmosClient
+
+import (
+	"testing"
+
+	"cosmossdk.io/math"
+	cosmostypes "github.com/cosmos/cosmos-sdk/types"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestFeeMarketGasPriceRequestMarshal(t *testing.T) {
+	b, err := (&feeMarketGasPriceRequest{Denom: "ufairy"}).Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	num, typ, n := protowire.ConsumeTag(b)
+	if n < 0 || num != 1 || typ != protowire.BytesType {
+		t.Fatalf("Marshal() tag = (%d, %d), want denom field 1 of bytes type", num, typ)
+	}
+	denom, n := protowire.ConsumeString(b[n:])
+	if n < 0 || denom != "ufairy" {
+		t.Fatalf("Marshal() denom = '%s', want 'ufairy'", denom)
+	}
+}
+
+func TestFeeMarketGasPriceResponseUnmarshal(t *testing.T) {
+	price := cosmostypes.NewDecCoinFromDec("ufairy", math.LegacyMustNewDecFromStr("0.0025"))
+	priceBytes, err := price.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	withPrice := protowire.AppendBytes(protowire.AppendTag(nil, 1, protowire.BytesType), priceBytes)
+
+	tests := []struct {
+		name      string
+		data      []byte
+		wantPrice cosmostypes.DecCoin
+		wantErr   bool
+	}{
+		{name: "price", data: withPrice, wantPrice: price},
+		{name: "empty response", data: nil},
+		{
+			name: "unknown fields are skipped",
+			data: protowire.AppendVarint(
+				protowire.AppendTag(
+					protowire.AppendString(protowire.AppendTag(withPrice, 3, protowire.BytesType), "unknown"),
+					2, protowire.VarintType,
+				),
+				42,
+			),
+			wantPrice: price,
+		},
+		{name: "truncated price", data: withPrice[:len(withPrice)-2], wantErr: true},
+		{name: "invalid tag", data: []byte{0xff}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp feeMarketGasPriceResponse
+			err := resp.Unmarshal(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if resp.Price.Denom != tt.wantPrice.Denom {
+				t.Fatalf("Unmarshal() denom = '%s', want '%s'", resp.Price.Denom, tt.wantPrice.Denom)
+			}
+			if tt.wantPrice.Amount.IsNil() {
+				if !resp.Price.Amount.IsNil() {
+					t.Fatalf("Unmarshal() amount = %s, want none", resp.Price.Amount)
+				}
+				return
+			}
+			if !resp.Price.Amount.Equal(tt.wantPrice.Amount) {
+				t.Fatalf("Unmarshal() amount = %s, want %s", resp.Price.Amount, tt.wantPrice.Amount)
+			}
+		})
+	}
+}
+
+func TestBumpedGasPrice(t *testing.T) {
+	tests := []struct {
+		name string
+		opts FeeOptions
+		want string
+	}{
+		{
+			name: "bumped by factor",
+			opts: FeeOptions{GasPrice: cosmostypes.NewDecCoinFromDec("ufairy", math.LegacyMustNewDecFromStr("0.02")), BumpFactor: math.LegacyMustNewDecFromStr("1.5")},
+			want: "0.030000000000000000ufairy",
+		},
+		{
+			name: "no bump factor",
+			opts: FeeOptions{GasPrice: cosmostypes.NewDecCoinFromDec("ufairy", math.LegacyMustNewDecFromStr("0.02"))},
+			want: "0.020000000000000000ufairy",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.BumpedGasPrice().String(); got != tt.want {
+				t.Fatalf("BumpedGasPrice() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
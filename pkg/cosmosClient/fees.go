@@ -0,0 +1,208 @@
+package cosmosClient
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"cosmossdk.io/math"
+	cosmostypes "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	txGasPrice = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "sharegenerationclient_tx_gas_price",
+		Help: "The gas price used by the latest submitted tx",
+	}, []string{"denom"})
+
+	txFee = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "sharegenerationclient_tx_fee",
+		Help: "The fee paid by the latest submitted tx",
+	}, []string{"denom"})
+
+	txFeeBumped = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "sharegenerationclient_tx_fee_bumped",
+		Help: "The total number of times a tx was resubmitted with a higher fee after insufficient fee rejection or expiry",
+	})
+)
+
+// FeeOptions defines how the fee of every tx is computed & who pays it.
+// With Auto enabled, gas price is the highest of GasPrice, the node minimum gas price & the fee market gas price,
+// and bumped by BumpFactor up to MaxBumps times when the tx is rejected for insufficient fee
+type FeeOptions struct {
+	GasPrice   cosmostypes.DecCoin
+	MaxFee     *cosmostypes.Coin
+	Granter    cosmostypes.AccAddress
+	Auto       bool
+	BumpFactor math.LegacyDec
+	MaxBumps   uint64
+}
+
+// ParseGasPrice parses gas price like '0.025ufair', the default denom is used if only the amount is given
+func ParseGasPrice(gasPrice, defaultDenom string) (cosmostypes.DecCoin, error) {
+	if len(gasPrice) == 0 {
+		return cosmostypes.NewDecCoinFromDec(defaultDenom, math.LegacyZeroDec()), nil
+	}
+
+	if amount, err := math.LegacyNewDecFromStr(gasPrice); err == nil {
+		return cosmostypes.NewDecCoinFromDec(defaultDenom, amount), nil
+	}
+
+	decCoin, err := cosmostypes.ParseDecCoin(gasPrice)
+	if err != nil {
+		return cosmostypes.DecCoin{}, errors.Wrapf(err, "invalid gas price: '%s'", gasPrice)
+	}
+	return decCoin, nil
+}
+
+// ParseFeeOptions parses the fee options from config values, empty max fee & granter are ignored
+func ParseFeeOptions(gasPrice, maxFee, granter, defaultDenom string) (FeeOptions, error) {
+	var opts FeeOptions
+	var err error
+
+	if opts.GasPrice, err = ParseGasPrice(gasPrice, defaultDenom); err != nil {
+		return opts, err
+	}
+
+	if len(maxFee) > 0 {
+		maxFeeCoin, err := cosmostypes.ParseCoinNormalized(maxFee)
+		if err != nil {
+			return opts, errors.Wrapf(err, "invalid max fee: '%s'", maxFee)
+		}
+		if maxFeeCoin.Denom != opts.GasPrice.Denom {
+			return opts, errors.Errorf("max fee denom '%s' does not match gas price denom '%s'", maxFeeCoin.Denom, opts.GasPrice.Denom)
+		}
+		opts.MaxFee = &maxFeeCoin
+	}
+
+	if len(granter) > 0 {
+		if opts.Granter, err = cosmostypes.GetFromBech32(granter, Bech32PrefixAccAddr); err != nil {
+			return opts, errors.Wrapf(err, "invalid fee granter: '%s'", granter)
+		}
+	}
+
+	return opts, nil
+}
+
+// ComputeFee returns gas price * gas limit rounded up, or an error if it is over the max fee
+func (o FeeOptions) ComputeFee(gasLimit uint64) (cosmostypes.Coins, error) {
+	if o.GasPrice.Amount.IsNil() || o.GasPrice.Amount.IsZero() {
+		return cosmostypes.NewCoins(), nil
+	}
+
+	amount := o.GasPrice.Amount.MulInt64(int64(gasLimit)).Ceil().TruncateInt()
+	fee := cosmostypes.NewCoin(o.GasPrice.Denom, amount)
+
+	if o.MaxFee != nil && fee.Amount.GT(o.MaxFee.Amount) {
+		return nil, errors.Errorf("tx fee %s exceeds max fee %s", fee, o.MaxFee)
+	}
+
+	return cosmostypes.NewCoins(fee), nil
+}
+
+// ParseBumpFactor parses the fee bump factor, it must be greater than 1
+func ParseBumpFactor(bumpFactor string) (math.LegacyDec, error) {
+	factor, err := math.LegacyNewDecFromStr(bumpFactor)
+	if err != nil {
+		return math.LegacyDec{}, errors.Wrapf(err, "invalid fee bump factor: '%s'", bumpFactor)
+	}
+	if factor.LTE(math.LegacyOneDec()) {
+		return math.LegacyDec{}, errors.Errorf("fee bump factor must be greater than 1, got: %s", factor)
+	}
+	return factor, nil
+}
+
+// BumpedGasPrice returns the gas price multiplied by the bump factor
+func (o FeeOptions) BumpedGasPrice() cosmostypes.DecCoin {
+	if o.BumpFactor.IsNil() || o.GasPrice.Amount.IsNil() {
+		return o.GasPrice
+	}
+	return cosmostypes.NewDecCoinFromDec(o.GasPrice.Denom, o.GasPrice.Amount.Mul(o.BumpFactor))
+}
+
+// bumpGasPrice returns the gas price to resubmit a tx with, a zero gas price would stay zero once bumped
+// so the node minimum gas price is used instead, failing if the node has none
+func (c *CosmosClient) bumpGasPrice(ctx context.Context, o FeeOptions) (cosmostypes.DecCoin, error) {
+	if !o.GasPrice.Amount.IsNil() && o.GasPrice.Amount.IsPositive() {
+		return o.BumpedGasPrice(), nil
+	}
+
+	minGasPrice, err := c.GetNodeMinGasPrice(ctx, o.GasPrice.Denom)
+	if err != nil {
+		return cosmostypes.DecCoin{}, errors.Wrap(err, "error querying node minimum gas price to bump a zero gas price")
+	}
+	if !minGasPrice.IsPositive() {
+		return cosmostypes.DecCoin{}, errors.New("unable to bump a zero gas price without any node minimum gas price, set Fee.gasPrice")
+	}
+	return cosmostypes.NewDecCoinFromDec(o.GasPrice.Denom, minGasPrice), nil
+}
+
+// DiscoverGasPrice returns the highest gas price of the configured one, the node minimum gas price
+// and the fee market gas price, failing queries are logged and ignored
+func (c *CosmosClient) DiscoverGasPrice(ctx context.Context) cosmostypes.DecCoin {
+	denom := c.feeOptions.GasPrice.Denom
+	gasPrice := c.feeOptions.GasPrice.Amount
+
+	minGasPrice, err := c.GetNodeMinGasPrice(ctx, denom)
+	if err != nil {
+		log.Printf("Unable to query node minimum gas price: %s\n", err.Error())
+	} else if minGasPrice.GT(gasPrice) {
+		gasPrice = minGasPrice
+	}
+
+	feeMarketGasPrice, found, err := c.GetFeeMarketGasPrice(ctx, denom)
+	if err != nil {
+		log.Printf("Unable to query fee market gas price: %s\n", err.Error())
+	} else if found && feeMarketGasPrice.GT(gasPrice) {
+		gasPrice = feeMarketGasPrice
+	}
+
+	return cosmostypes.NewDecCoinFromDec(denom, gasPrice)
+}
+
+func isInsufficientFee(resp *cosmostypes.TxResponse) bool {
+	if resp == nil || resp.Code == 0 {
+		return false
+	}
+	if resp.Codespace == sdkerrors.ErrInsufficientFee.Codespace() && resp.Code == sdkerrors.ErrInsufficientFee.ABCICode() {
+		return true
+	}
+	return strings.Contains(resp.RawLog, "insufficient fee")
+}
+
+func recordTxFee(gasPrice cosmostypes.DecCoin, fee cosmostypes.Coins) {
+	gasPriceFloat, _ := gasPrice.Amount.Float64()
+	txGasPrice.WithLabelValues(gasPrice.Denom).Set(gasPriceFloat)
+	txFee.WithLabelValues(gasPrice.Denom).Set(float64(fee.AmountOf(gasPrice.Denom).Int64()))
+}
+
+// TxEstimate is the gas & fee a msg would be broadcast with
+type TxEstimate struct {
+	GasLimit uint64
+	GasPrice cosmostypes.DecCoin
+	Fee      cosmostypes.Coins
+}
+
+// EstimateTx simulates the msg and returns the gas & fee it would be broadcast with, without broadcasting it
+func (c *CosmosClient) EstimateTx(ctx context.Context, msg cosmostypes.Msg) (*TxEstimate, error) {
+	gasLimit, err := c.calculateGas(msg, c.sequence.Next())
+	if err != nil {
+		return nil, errors.Wrap(err, "error simulating tx")
+	}
+
+	feeOptions := c.initialFeeOptions(ctx)
+	fee, err := feeOptions.ComputeFee(gasLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TxEstimate{
+		GasLimit: gasLimit,
+		GasPrice: feeOptions.GasPrice,
+		Fee:      fee,
+	}, nil
+}
@@ -0,0 +1,78 @@
+package cosmosClient
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/cosmos/cosmos-sdk/crypto/keys/secp256k1"
+	cosmostypes "github.com/cosmos/cosmos-sdk/types"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+)
+
+func newTestOfflineClient(t *testing.T) *CosmosClient {
+	t.Helper()
+	privateKey := secp256k1.GenPrivKey()
+	c, err := NewOfflineCosmosClient(hex.EncodeToString(privateKey.Key), SignParams{
+		ChainID:       "fairyring-test",
+		AccountNumber: 3,
+		Sequence:      7,
+	})
+	if err != nil {
+		t.Fatalf("error creating offline client: %s", err)
+	}
+	return c
+}
+
+func TestSignOfflineFeeGranter(t *testing.T) {
+	c := newTestOfflineClient(t)
+	granter := cosmostypes.AccAddress(secp256k1.GenPrivKey().PubKey().Address())
+
+	tests := []struct {
+		name        string
+		granter     string
+		wantGranter cosmostypes.AccAddress
+		wantErr     bool
+	}{
+		{name: "no granter", granter: ""},
+		{name: "granter", granter: granter.String(), wantGranter: granter},
+		{name: "invalid granter", granter: "cosmos1invalid", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := &banktypes.MsgSend{
+				FromAddress: c.GetAddress(),
+				ToAddress:   granter.String(),
+				Amount:      cosmostypes.NewCoins(cosmostypes.NewInt64Coin("ufair", 1)),
+			}
+			txBytes, _, err := c.SignOffline(context.Background(), msg, SignParams{
+				ChainID:       "fairyring-test",
+				AccountNumber: 3,
+				Sequence:      7,
+				GasLimit:      200000,
+				Fee:           "5000ufair",
+				Granter:       tt.granter,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SignOffline() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			decoded, err := c.encodingConfig.TxConfig.TxDecoder()(txBytes)
+			if err != nil {
+				t.Fatalf("error decoding tx: %s", err)
+			}
+			feeTx := decoded.(cosmostypes.FeeTx)
+			if !cosmostypes.AccAddress(feeTx.FeeGranter()).Equals(tt.wantGranter) {
+				t.Fatalf("fee granter = %s, want %s", cosmostypes.AccAddress(feeTx.FeeGranter()), tt.wantGranter)
+			}
+			// The client always pays the fees, or has them granted
+			if !cosmostypes.AccAddress(feeTx.FeePayer()).Equals(c.GetAccAddress()) {
+				t.Fatalf("fee payer = %s, want %s", cosmostypes.AccAddress(feeTx.FeePayer()), c.GetAccAddress())
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package cosmosClient
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	grpctypes "github.com/cosmos/cosmos-sdk/types/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// prunedHeightLogs are the messages the node answers queries of a pruned height with, as generic invalid requests
+var prunedHeightLogs = []string{
+	"failed to load state at height",
+	"version does not exist",
+	"ensure height has not been pruned",
+}
+
+// AtHeight returns a context making the queries of the client, proven ones included,
+// read the state at the given height instead of the latest one
+func AtHeight(ctx context.Context, height int64) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, grpctypes.GRPCBlockHeightHeader, strconv.FormatInt(height, 10))
+}
+
+// queryHeight returns the height set with AtHeight, 0 for the latest one
+func queryHeight(ctx context.Context) int64 {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if !ok {
+		return 0
+	}
+	values := md.Get(grpctypes.GRPCBlockHeightHeader)
+	if len(values) == 0 {
+		return 0
+	}
+	height, err := strconv.ParseInt(values[len(values)-1], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return height
+}
+
+// IsHeightPruned returns true if the node failed the query as it no longer has the state of the queried height
+func IsHeightPruned(err error) bool {
+	if err == nil {
+		return false
+	}
+	for _, log := range prunedHeightLogs {
+		if strings.Contains(err.Error(), log) {
+			return true
+		}
+	}
+	return false
+}
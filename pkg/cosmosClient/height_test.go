@@ -0,0 +1,57 @@
+package cosmosClient
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAtHeight(t *testing.T) {
+	ctx := context.Background()
+	if height := queryHeight(ctx); height != 0 {
+		t.Fatalf("queryHeight() = %d, want 0 without height", height)
+	}
+	if height := queryHeight(AtHeight(ctx, 1234)); height != 1234 {
+		t.Fatalf("queryHeight() = %d, want 1234", height)
+	}
+	if height := queryHeight(AtHeight(AtHeight(ctx, 1234), 5678)); height != 5678 {
+		t.Fatalf("queryHeight() = %d, want the latest set height 5678", height)
+	}
+}
+
+func TestIsHeightPruned(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "no error",
+		},
+		{
+			name: "grpc query of a pruned height",
+			err:  status.Error(codes.Unknown, "failed to load state at height 100; version does not exist (latest height: 5000): invalid request"),
+			want: true,
+		},
+		{
+			name: "proven query of a pruned height",
+			err:  errors.New("store query failed with code 18: proof is unexpectedly empty; ensure height has not been pruned: invalid request"),
+			want: true,
+		},
+		{
+			name: "other query error",
+			err:  status.Error(codes.Unavailable, "connection refused"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsHeightPruned(tt.err); got != tt.want {
+				t.Fatalf("IsHeightPruned() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
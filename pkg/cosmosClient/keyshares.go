@@ -0,0 +1,55 @@
+package cosmosClient
+
+import (
+	"context"
+
+	"github.com/Fairblock/fairyring/api/fairyring/keyshare"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GetKeyshare returns the keyshare submitted by the address for the given height, found is false if none was submitted
+func (c *CosmosClient) GetKeyshare(ctx context.Context, address string, height uint64) (ks *keyshare.Keyshare, found bool, err error) {
+	resp, err := c.keyshareQueryClient.Keyshare(ctx, &keyshare.QueryKeyshareRequest{
+		Validator:   address,
+		BlockHeight: height,
+	})
+	if status.Code(err) == codes.NotFound {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return resp.Keyshare, true, nil
+}
+
+// GetActivePubKeyHolders returns the addresses the active pub key shares were encrypted for,
+// the validators or their authorized addresses submitting keyshares
+func (c *CosmosClient) GetActivePubKeyHolders(ctx context.Context) ([]string, error) {
+	pubKeyResp, err := c.keyshareQueryClient.Pubkey(ctx, &keyshare.QueryPubkeyRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	holders := make([]string, 0)
+	if pubKeyResp.ActivePubkey == nil {
+		return holders, nil
+	}
+	for _, eks := range pubKeyResp.ActivePubkey.EncryptedKeyshares {
+		holders = append(holders, eks.Validator)
+	}
+	return holders, nil
+}
+
+// GetDecryptionKey returns the aggregated decryption key for the given height, found is false if it was not aggregated.
+// Pep module has no decryption key query, keys are aggregated by the keyshare module
+func (c *CosmosClient) GetDecryptionKey(ctx context.Context, height uint64) (key *keyshare.DecryptionKey, found bool, err error) {
+	resp, err := c.keyshareQueryClient.DecryptionKey(ctx, &keyshare.QueryDecryptionKeyRequest{Height: height})
+	if status.Code(err) == codes.NotFound {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return resp.DecryptionKey, true, nil
+}
@@ -0,0 +1,200 @@
+package cosmosClient
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tmtypes "github.com/cometbft/cometbft/types"
+	"github.com/cosmos/cosmos-sdk/client/grpc/cmtservice"
+	cosmostypes "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/tx"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	TxStatusBroadcast = "broadcast"
+	TxStatusIncluded  = "included"
+	TxStatusFailed    = "failed"
+	TxStatusExpired   = "expired"
+)
+
+var ErrTxExpired = errors.New("tx expired before being included in a block")
+
+var txLifecycle = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "sharegenerationclient_tx_lifecycle",
+	Help: "The total number of submitted txs reaching each lifecycle status",
+}, []string{"status"})
+
+// TxOptions sets how long a submitted tx is valid and how many times it is rebroadcast once expired,
+// a timeout of 0 block disables the timeout height
+type TxOptions struct {
+	TimeoutBlocks   uint64
+	MaxRebroadcasts uint64
+}
+
+// InFlightTxError is returned when the context is done while the tx
+// may have been broadcast, but is not known to be included yet
+type InFlightTxError struct {
+	Hash          string
+	Sequence      uint64
+	TimeoutHeight uint64
+	Err           error
+}
+
+func (e *InFlightTxError) Error() string {
+	return fmt.Sprintf("tx %s in flight: %s", e.Hash, e.Err.Error())
+}
+
+func (e *InFlightTxError) Unwrap() error {
+	return e.Err
+}
+
+// broadcastResult is the tx broadcast with the given hash, the response is nil
+// if the broadcast request failed while the node may have received the tx
+type broadcastResult struct {
+	Hash          string
+	TxResponse    *cosmostypes.TxResponse
+	Sequence      uint64
+	TimeoutHeight uint64
+	FeeOptions    FeeOptions
+}
+
+// SetTxOptions sets the timeout height & rebroadcast policy of submitted txs, no timeout by default
+func (c *CosmosClient) SetTxOptions(opts TxOptions) {
+	c.txOptions = opts
+}
+
+// GetChainHeight returns the height of the latest block, GetLatestHeight returns the pep module one
+func (c *CosmosClient) GetChainHeight(ctx context.Context) (int64, error) {
+	header, err := c.GetBlockHeader(ctx, 0)
+	if err != nil {
+		return 0, err
+	}
+	return header.Height, nil
+}
+
+// GetBlockHeader returns the header of the block at the given height, the latest one if height is 0
+func (c *CosmosClient) GetBlockHeader(ctx context.Context, height int64) (*cmtservice.Header, error) {
+	service := cmtservice.NewServiceClient(c.grpcConn)
+
+	var block *cmtservice.Block
+	if height == 0 {
+		resp, err := service.GetLatestBlock(ctx, &cmtservice.GetLatestBlockRequest{})
+		if err != nil {
+			return nil, err
+		}
+		block = resp.SdkBlock
+	} else {
+		resp, err := service.GetBlockByHeight(ctx, &cmtservice.GetBlockByHeightRequest{Height: height})
+		if err != nil {
+			return nil, err
+		}
+		block = resp.SdkBlock
+	}
+
+	if block == nil {
+		return nil, errors.New("block response does not contain any block")
+	}
+	return &block.Header, nil
+}
+
+func txHash(txBytes []byte) string {
+	return strings.ToUpper(hex.EncodeToString(tmtypes.Tx(txBytes).Hash()))
+}
+
+func (c *CosmosClient) nextTimeoutHeight(ctx context.Context) (uint64, error) {
+	if c.txOptions.TimeoutBlocks == 0 {
+		return 0, nil
+	}
+	height, err := c.GetChainHeight(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(height) + c.txOptions.TimeoutBlocks, nil
+}
+
+// SubmitTx broadcasts the msg and tracks it until it is included in a block.
+// A tx expiring from the mempool is re-signed with a bumped gas price and rebroadcast,
+// the returned response may have a non-zero code if the tx failed on execution.
+// Once broadcast, the tx is tracked until it is included or expires, so an error is only
+// returned while no tx can land anymore, apart from InFlightTxError when the context is done
+func (c *CosmosClient) SubmitTx(ctx context.Context, msg cosmostypes.Msg, adjustGas bool, rate time.Duration) (*tx.GetTxResponse, error) {
+	feeOptions := c.initialFeeOptions(ctx)
+
+	for rebroadcasts := uint64(0); ; rebroadcasts++ {
+		result, err := c.broadcast(ctx, msg, adjustGas, feeOptions)
+		if err != nil {
+			txLifecycle.WithLabelValues(TxStatusFailed).Inc()
+			return nil, err
+		}
+
+		hash := result.Hash
+		txLifecycle.WithLabelValues(TxStatusBroadcast).Inc()
+		log.Printf(
+			"Tx %s broadcasted | Sequence: %d | Gas price: %s | Timeout height: %d\n",
+			hash, result.Sequence, result.FeeOptions.GasPrice, result.TimeoutHeight,
+		)
+
+		resp, err := c.WaitForTx(ctx, hash, result.TimeoutHeight, rate)
+		for err != nil && !errors.Is(err, ErrTxExpired) && ctx.Err() == nil {
+			// Signing a new tx could get both included, keep tracking this one instead
+			log.Printf("Unable to track tx %s, retrying: %s\n", hash, err.Error())
+			select {
+			case <-ctx.Done():
+			case <-time.After(rate):
+			}
+			resp, err = c.WaitForTx(ctx, hash, result.TimeoutHeight, rate)
+		}
+		if errors.Is(err, ErrTxExpired) {
+			txLifecycle.WithLabelValues(TxStatusExpired).Inc()
+			log.Printf("Tx %s expired at height %d without being included\n", hash, result.TimeoutHeight)
+
+			// Expired tx never consumed its sequence, the next one is signed with it again
+			c.broadcastMu.Lock()
+			c.sequence.Reset(result.Sequence)
+			c.broadcastMu.Unlock()
+
+			if rebroadcasts >= c.txOptions.MaxRebroadcasts {
+				return nil, errors.Wrapf(err, "tx %s not included after %d rebroadcast(s)", hash, rebroadcasts)
+			}
+
+			feeOptions = result.FeeOptions
+			if feeOptions.GasPrice, err = c.bumpGasPrice(ctx, feeOptions); err != nil {
+				return nil, errors.Wrapf(ErrTxExpired, "tx %s not rebroadcast: %s", hash, err.Error())
+			}
+			txFeeBumped.Inc()
+			log.Printf("Rebroadcasting with gas price %s (%d / %d)\n", feeOptions.GasPrice, rebroadcasts+1, c.txOptions.MaxRebroadcasts)
+			continue
+		}
+		if err != nil {
+			return nil, &InFlightTxError{
+				Hash:          hash,
+				Sequence:      result.Sequence,
+				TimeoutHeight: result.TimeoutHeight,
+				Err:           err,
+			}
+		}
+
+		if resp.TxResponse.Code != 0 {
+			txLifecycle.WithLabelValues(TxStatusFailed).Inc()
+			log.Printf("Tx %s failed at height %d, code: %d\n", hash, resp.TxResponse.Height, resp.TxResponse.Code)
+			return resp, nil
+		}
+
+		txLifecycle.WithLabelValues(TxStatusIncluded).Inc()
+		log.Printf("Tx %s included at height %d\n", hash, resp.TxResponse.Height)
+		for _, e := range DecodePubkeyEvents(resp.TxResponse.Events) {
+			log.Printf(
+				"Event %s | Pub Key: %s | Validators: %d | Expires at: %d | Active Pub Key expires at: %d\n",
+				e.Type, e.Pubkey, e.NumberOfValidators, e.ExpiryHeight, e.ActivePubkeyExpiryHeight,
+			)
+		}
+		return resp, nil
+	}
+}
@@ -0,0 +1,197 @@
+package cosmosClient
+
+import (
+	"context"
+	"encoding/hex"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/crypto/keys/secp256k1"
+	cosmostypes "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/tx"
+	"github.com/cosmos/cosmos-sdk/types/tx/signing"
+	authsigning "github.com/cosmos/cosmos-sdk/x/auth/signing"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	"github.com/pkg/errors"
+)
+
+// SignParams is everything needed to sign a tx without any node connection
+type SignParams struct {
+	ChainID       string `json:"chain_id"`
+	AccountNumber uint64 `json:"account_number"`
+	Sequence      uint64 `json:"sequence"`
+	GasLimit      uint64 `json:"gas_limit"`
+	Fee           string `json:"fee"`
+	Granter       string `json:"granter,omitempty"`
+	TimeoutHeight uint64 `json:"timeout_height"`
+}
+
+// SignedTxInfo is what a signed tx commits to, decoded from its bytes
+type SignedTxInfo struct {
+	Hash          string
+	Msgs          []cosmostypes.Msg
+	Sequence      uint64
+	TimeoutHeight uint64
+}
+
+// ExportSignParams returns the params to sign the msg offline with the current account sequence.
+// The gas limit is simulated with the msg if not given, the timeout height is 0 without timeout blocks
+func (c *CosmosClient) ExportSignParams(ctx context.Context, msg cosmostypes.Msg, gasLimit, timeoutBlocks uint64) (*SignParams, error) {
+	if err := c.UpdateClientAccountInfo(ctx); err != nil {
+		return nil, errors.Wrap(err, "error updating account info")
+	}
+
+	account := c.getAccount()
+	if gasLimit == 0 {
+		var err error
+		if gasLimit, err = c.calculateGas(msg, account.Sequence); err != nil {
+			return nil, errors.Wrap(err, "error simulating tx")
+		}
+	}
+
+	feeOptions := c.initialFeeOptions(ctx)
+	fee, err := feeOptions.ComputeFee(gasLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	params := SignParams{
+		ChainID:       c.chainID,
+		AccountNumber: account.AccountNumber,
+		Sequence:      account.Sequence,
+		GasLimit:      gasLimit,
+		Fee:           fee.String(),
+	}
+	if !feeOptions.Granter.Empty() {
+		params.Granter = feeOptions.Granter.String()
+	}
+
+	if timeoutBlocks > 0 {
+		height, err := c.GetChainHeight(ctx)
+		if err != nil {
+			return nil, err
+		}
+		params.TimeoutHeight = uint64(height) + timeoutBlocks
+	}
+	return &params, nil
+}
+
+// NewOfflineCosmosClient returns a client only able to sign txs with the given params, it never connects to any node
+func NewOfflineCosmosClient(privateKeyHex string, params SignParams) (*CosmosClient, error) {
+	keyBytes, err := hex.DecodeString(privateKeyHex)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey := secp256k1.PrivKey{Key: keyBytes}
+	pubKey := privateKey.PubKey()
+
+	setBech32Prefixes()
+	accAddr := cosmostypes.AccAddress(pubKey.Address())
+
+	return &CosmosClient{
+		privateKey: privateKey,
+		publicKey:  pubKey,
+		accAddress: accAddr,
+		account: authtypes.BaseAccount{
+			Address:       accAddr.String(),
+			AccountNumber: params.AccountNumber,
+			Sequence:      params.Sequence,
+		},
+		chainID:        params.ChainID,
+		encodingConfig: MakeEncodingConfig(),
+		signMode:       signing.SignMode_SIGN_MODE_DIRECT,
+		sequence:       NewSequenceManager(params.Sequence),
+	}, nil
+}
+
+// SignOffline signs the msg with the params, and returns the tx bytes & hash
+func (c *CosmosClient) SignOffline(ctx context.Context, msg cosmostypes.Msg, params SignParams) ([]byte, string, error) {
+	fee, err := cosmostypes.ParseCoinsNormalized(params.Fee)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "invalid fee: '%s'", params.Fee)
+	}
+
+	var granter cosmostypes.AccAddress
+	if len(params.Granter) > 0 {
+		if granter, err = cosmostypes.GetFromBech32(params.Granter, Bech32PrefixAccAddr); err != nil {
+			return nil, "", errors.Wrapf(err, "invalid fee granter: '%s'", params.Granter)
+		}
+	}
+
+	txBytes, err := c.buildSignedTx(ctx, msg, params.GasLimit, fee, granter, params.Sequence, params.TimeoutHeight)
+	if err != nil {
+		return nil, "", err
+	}
+	return txBytes, txHash(txBytes), nil
+}
+
+// DecodeSignedTx returns the msgs, signer sequence & timeout height of a signed tx
+func (c *CosmosClient) DecodeSignedTx(txBytes []byte) (*SignedTxInfo, error) {
+	decoded, err := c.encodingConfig.TxConfig.TxDecoder()(txBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding tx")
+	}
+
+	sigTx, ok := decoded.(authsigning.SigVerifiableTx)
+	if !ok {
+		return nil, errors.New("tx is not signed")
+	}
+	sigs, err := sigTx.GetSignaturesV2()
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting tx signatures")
+	}
+	if len(sigs) != 1 {
+		return nil, errors.Errorf("expected 1 tx signature, got %d", len(sigs))
+	}
+
+	info := SignedTxInfo{
+		Hash:     txHash(txBytes),
+		Msgs:     decoded.GetMsgs(),
+		Sequence: sigs[0].Sequence,
+	}
+	if timeoutTx, ok := decoded.(cosmostypes.TxWithTimeoutHeight); ok {
+		info.TimeoutHeight = timeoutTx.GetTimeoutHeight()
+	}
+	return &info, nil
+}
+
+// BroadcastSignedTx broadcasts a tx signed offline and waits until it is included in a block,
+// the returned response may have a non-zero code if the tx failed on execution
+func (c *CosmosClient) BroadcastSignedTx(ctx context.Context, txBytes []byte, timeoutHeight uint64, rate time.Duration) (*tx.GetTxResponse, error) {
+	resp, err := c.txClient.BroadcastTx(
+		ctx,
+		&tx.BroadcastTxRequest{
+			TxBytes: txBytes,
+			Mode:    tx.BroadcastMode_BROADCAST_MODE_SYNC,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.handleBroadcastResult(resp.TxResponse, nil); err != nil {
+		txLifecycle.WithLabelValues(TxStatusFailed).Inc()
+		return nil, err
+	}
+
+	hash := resp.TxResponse.TxHash
+	txLifecycle.WithLabelValues(TxStatusBroadcast).Inc()
+
+	included, err := c.WaitForTx(ctx, hash, timeoutHeight, rate)
+	if errors.Is(err, ErrTxExpired) {
+		txLifecycle.WithLabelValues(TxStatusExpired).Inc()
+		return nil, err
+	}
+	if err != nil && ctx.Err() != nil {
+		return nil, &InFlightTxError{Hash: hash, TimeoutHeight: timeoutHeight, Err: err}
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "error tracking tx %s", hash)
+	}
+
+	if included.TxResponse.Code != 0 {
+		txLifecycle.WithLabelValues(TxStatusFailed).Inc()
+	} else {
+		txLifecycle.WithLabelValues(TxStatusIncluded).Inc()
+	}
+	return included, nil
+}
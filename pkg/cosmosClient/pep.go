@@ -0,0 +1,73 @@
+package cosmosClient
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"strconv"
+
+	enc "github.com/FairBlock/DistributedIBE/encryption"
+	"github.com/Fairblock/fairyring/x/pep/types"
+	abcitypes "github.com/cometbft/cometbft/abci/types"
+	cosmostypes "github.com/cosmos/cosmos-sdk/types"
+	bls "github.com/drand/kyber-bls12381"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GetPepNonce returns the sequence the next encrypted tx of the client has to be signed with
+func (c *CosmosClient) GetPepNonce(ctx context.Context) (uint64, error) {
+	resp, err := c.pepQueryClient.PepNonce(ctx, &types.QueryPepNonceRequest{Address: c.GetAddress()})
+	if status.Code(err) == codes.NotFound {
+		// Pep module starts the nonce of a new address at 1
+		return 1, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return resp.PepNonce.Nonce, nil
+}
+
+// EncryptTx signs the msg with the given pep nonce & encrypts the tx to the pub key for the target height,
+// the returned hex data is what MsgSubmitEncryptedTx expects
+func (c *CosmosClient) EncryptTx(ctx context.Context, msg cosmostypes.Msg, pepNonce uint64, pubKey string, targetHeight uint64) (string, error) {
+	// Encrypted tx is checked with the account sequence, so gas can not be simulated
+	feeOptions := FeeOptions{GasPrice: c.initialFeeOptions(ctx).GasPrice}
+	txBytes, _, err := c.signTxMsg(ctx, msg, false, feeOptions, pepNonce, 0)
+	if err != nil {
+		return "", errors.Wrap(err, "error signing tx")
+	}
+
+	pubKeyBytes, err := hex.DecodeString(pubKey)
+	if err != nil {
+		return "", errors.Wrap(err, "error decoding pub key")
+	}
+	pubKeyPoint := bls.NewBLS12381Suite().G1().Point()
+	if err = pubKeyPoint.UnmarshalBinary(pubKeyBytes); err != nil {
+		return "", errors.Wrap(err, "error unmarshalling pub key")
+	}
+
+	var encrypted bytes.Buffer
+	if err = enc.Encrypt(pubKeyPoint, []byte(strconv.FormatUint(targetHeight, 10)), &encrypted, bytes.NewReader(txBytes)); err != nil {
+		return "", errors.Wrap(err, "error encrypting tx")
+	}
+	return hex.EncodeToString(encrypted.Bytes()), nil
+}
+
+// EncryptedTxIndex returns the index of the encrypted tx submitted by the tx with the given events
+func EncryptedTxIndex(events []abcitypes.Event) (uint64, bool) {
+	for _, e := range events {
+		if e.Type != types.SubmittedEncryptedTxEventType {
+			continue
+		}
+		for _, attr := range e.Attributes {
+			if attr.Key != types.SubmittedEncryptedTxEventIndex {
+				continue
+			}
+			index, err := strconv.ParseUint(attr.Value, 10, 64)
+			return index, err == nil
+		}
+	}
+	return 0, false
+}
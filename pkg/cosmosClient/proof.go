@@ -0,0 +1,256 @@
+package cosmosClient
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"time"
+
+	"cosmossdk.io/store/rootmulti"
+	keysharetypes "github.com/Fairblock/fairyring/x/keyshare/types"
+	dbm "github.com/cometbft/cometbft-db"
+	"github.com/cometbft/cometbft/crypto/merkle"
+	"github.com/cometbft/cometbft/light"
+	lightdb "github.com/cometbft/cometbft/light/store/db"
+	rpcclient "github.com/cometbft/cometbft/rpc/client"
+	tmclient "github.com/cometbft/cometbft/rpc/client/http"
+	"github.com/cosmos/cosmos-sdk/codec"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	cosmostypes "github.com/cosmos/cosmos-sdk/types"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	keyshareStoreName = keysharetypes.StoreKey
+	authStoreName     = authtypes.StoreKey
+)
+
+// ProofVerifier checks ABCI query results with merkle proofs against
+// app hashes from headers verified by a CometBFT light client
+type ProofVerifier struct {
+	lightClient  *light.Client
+	rpcClient    *tmclient.HTTP
+	proofRuntime *merkle.ProofRuntime
+	cdc          codec.Codec
+}
+
+type ProofVerifierOptions struct {
+	ChainID        string
+	RPCEndpoint    string
+	Witnesses      []string
+	TrustedHeight  int64
+	TrustedHash    []byte
+	TrustingPeriod time.Duration
+	DBDir          string
+}
+
+func NewProofVerifier(ctx context.Context, opts ProofVerifierOptions) (*ProofVerifier, error) {
+	witnesses := make([]string, 0, len(opts.Witnesses))
+	for _, w := range opts.Witnesses {
+		if w != opts.RPCEndpoint {
+			witnesses = append(witnesses, w)
+		}
+	}
+	// Headers are cross-checked with the witnesses for fork detection,
+	// the primary as its own witness would not detect anything
+	if len(witnesses) == 0 {
+		return nil, errors.New("light client requires at least one witness distinct from the primary node")
+	}
+
+	db := openLightClientDB(opts.DBDir)
+
+	lightClient, err := light.NewHTTPClient(
+		ctx,
+		opts.ChainID,
+		light.TrustOptions{
+			Period: opts.TrustingPeriod,
+			Height: opts.TrustedHeight,
+			Hash:   opts.TrustedHash,
+		},
+		opts.RPCEndpoint,
+		witnesses,
+		lightdb.New(db, opts.ChainID),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating light client")
+	}
+
+	rpcClient, err := tmclient.New(opts.RPCEndpoint, "/websocket")
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating rpc client")
+	}
+
+	return &ProofVerifier{
+		lightClient:  lightClient,
+		rpcClient:    rpcClient,
+		proofRuntime: rootmulti.DefaultProofRuntime(),
+		cdc:          MakeEncodingConfig().Codec,
+	}, nil
+}
+
+// openLightClientDB opens the light client store in the dir, or an in-memory one if the dir is empty
+// or already locked by another process, headers are then verified again from the trusted height
+func openLightClientDB(dir string) dbm.DB {
+	if len(dir) == 0 {
+		return dbm.NewMemDB()
+	}
+
+	db, err := dbm.NewGoLevelDB("light-client", dir)
+	if err != nil {
+		log.Printf("Unable to open light client database in %s, using an in-memory one: %s\n", dir, err.Error())
+		return dbm.NewMemDB()
+	}
+	return db
+}
+
+// QueryVerified returns the value of the key in the given module store, nil if the key does not exist,
+// only after the merkle proof is verified against the app hash of a light client verified header,
+// the state is read at the height set with AtHeight, the latest one otherwise
+func (v *ProofVerifier) QueryVerified(ctx context.Context, storeName string, key []byte) ([]byte, error) {
+	resp, err := v.rpcClient.ABCIQueryWithOptions(
+		ctx,
+		"/store/"+storeName+"/key",
+		key,
+		rpcclient.ABCIQueryOptions{Height: queryHeight(ctx), Prove: true},
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "error querying store with proof")
+	}
+
+	if !resp.Response.IsOK() {
+		return nil, errors.Errorf("store query failed with code %d: %s", resp.Response.Code, resp.Response.Log)
+	}
+
+	if resp.Response.ProofOps == nil || len(resp.Response.ProofOps.Ops) == 0 {
+		return nil, errors.New("store query response does not contain any proof")
+	}
+
+	// App hash of height H is committed in the header of height H + 1
+	lightBlock, err := v.lightClient.VerifyLightBlockAtHeight(ctx, resp.Response.Height+1, time.Now())
+	if err != nil {
+		return nil, errors.Wrapf(err, "error verifying light block at height %d", resp.Response.Height+1)
+	}
+
+	keyPath := merkle.KeyPath{}.
+		AppendKey([]byte(storeName), merkle.KeyEncodingURL).
+		AppendKey(key, merkle.KeyEncodingURL).
+		String()
+
+	if len(resp.Response.Value) == 0 {
+		if err = v.proofRuntime.VerifyAbsence(resp.Response.ProofOps, lightBlock.AppHash, keyPath); err != nil {
+			return nil, errors.Wrap(err, "error verifying absence proof")
+		}
+		return nil, nil
+	}
+
+	if err = v.proofRuntime.VerifyValue(resp.Response.ProofOps, lightBlock.AppHash, keyPath, resp.Response.Value); err != nil {
+		return nil, errors.Wrap(err, "error verifying value proof")
+	}
+
+	return resp.Response.Value, nil
+}
+
+// VerifyValidatorSet verifies the validator is registered in the keyshare module.
+// Proofs can only be made per key and the validator set can not be listed from the store, so the completeness
+// of the validator set returned by the node is NOT verified: an omitted validator can not be detected,
+// the node is however unable to add a validator that does not exist
+func (v *ProofVerifier) VerifyValidatorSet(ctx context.Context, index, validator string, isActive bool) error {
+	key := append([]byte(keysharetypes.ValidatorSetKeyPrefix), keysharetypes.ValidatorSetKey(index)...)
+	value, err := v.QueryVerified(ctx, keyshareStoreName, key)
+	if err != nil {
+		return err
+	}
+	if value == nil {
+		return errors.Errorf("validator %s is not in the keyshare validator set", validator)
+	}
+
+	var validatorSet keysharetypes.ValidatorSet
+	if err = validatorSet.Unmarshal(value); err != nil {
+		return errors.Wrap(err, "error unmarshalling proven validator set")
+	}
+
+	if validatorSet.Validator != validator || validatorSet.IsActive != isActive {
+		return errors.Errorf("validator set entry of %s does not match the proven one", validator)
+	}
+	return nil
+}
+
+// VerifyAuthorizedAddress verifies target is currently authorized by the given validator.
+// Authorized addresses are keyed by target, so an authorization omitted by the node can not be detected
+func (v *ProofVerifier) VerifyAuthorizedAddress(ctx context.Context, target, authorizedBy string) error {
+	key := append([]byte(keysharetypes.AuthorizedAddressKeyPrefix), keysharetypes.AuthorizedAddressKey(target)...)
+	value, err := v.QueryVerified(ctx, keyshareStoreName, key)
+	if err != nil {
+		return err
+	}
+	if value == nil {
+		return errors.Errorf("authorized address %s does not exist", target)
+	}
+
+	var authorizedAddress keysharetypes.AuthorizedAddress
+	if err = authorizedAddress.Unmarshal(value); err != nil {
+		return errors.Wrap(err, "error unmarshalling proven authorized address")
+	}
+
+	if !authorizedAddress.IsAuthorized || authorizedAddress.Target != target || authorizedAddress.AuthorizedBy != authorizedBy {
+		return errors.Errorf("authorization of %s by %s does not match the proven one", target, authorizedBy)
+	}
+	return nil
+}
+
+// VerifyAccountPubKey verifies the account exists on chain with the given secp256k1 public key
+func (v *ProofVerifier) VerifyAccountPubKey(ctx context.Context, address string, pubKey []byte) error {
+	account, err := v.provenAccount(ctx, address)
+	if err != nil {
+		return err
+	}
+
+	provenPubKey, err := accountSecp256k1PubKey(account)
+	if err != nil {
+		return err
+	}
+
+	if provenPubKey == nil || !bytes.Equal(provenPubKey.Key, pubKey) {
+		return errors.Errorf("public key of account %s does not match the proven one", address)
+	}
+	return nil
+}
+
+// VerifyAccountWithoutPubKey verifies the account has no secp256k1 public key on chain,
+// so a validator is only skipped when it really can not be given a share
+func (v *ProofVerifier) VerifyAccountWithoutPubKey(ctx context.Context, address string) error {
+	account, err := v.provenAccount(ctx, address)
+	if err != nil {
+		return err
+	}
+
+	provenPubKey, err := accountSecp256k1PubKey(account)
+	if err == nil && provenPubKey != nil {
+		return errors.Errorf("account %s has a proven secp256k1 public key the node did not return", address)
+	}
+	return nil
+}
+
+func (v *ProofVerifier) provenAccount(ctx context.Context, address string) (cosmostypes.AccountI, error) {
+	accAddr, err := cosmostypes.AccAddressFromBech32(address)
+	if err != nil {
+		return nil, err
+	}
+
+	key := append(authtypes.AddressStoreKeyPrefix.Bytes(), accAddr.Bytes()...)
+	value, err := v.QueryVerified(ctx, authStoreName, key)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, errors.Errorf("account %s does not exist", address)
+	}
+
+	var accountAny codectypes.Any
+	if err = accountAny.Unmarshal(value); err != nil {
+		return nil, errors.Wrap(err, "error decoding proven account")
+	}
+
+	return unpackAccount(v.cdc, &accountAny)
+}
@@ -0,0 +1,111 @@
+package cosmosClient
+
+import (
+	"regexp"
+	"strconv"
+	"sync"
+
+	cosmostypes "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+var sequenceMismatchRegex = regexp.MustCompile(`account sequence mismatch, expected (\d+), got (\d+)`)
+
+// SequenceManager tracks the sequence of the next tx & the txs accepted
+// to the mempool but not committed yet
+type SequenceManager struct {
+	mu      sync.Mutex
+	next    uint64
+	pending map[uint64]string
+}
+
+func NewSequenceManager(sequence uint64) *SequenceManager {
+	return &SequenceManager{
+		next:    sequence,
+		pending: make(map[uint64]string),
+	}
+}
+
+// Next returns the sequence to sign the next tx with
+func (m *SequenceManager) Next() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.next
+}
+
+// Accepted records the tx signed with the given sequence was accepted to the mempool
+func (m *SequenceManager) Accepted(sequence uint64, txHash string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pending[sequence] = txHash
+	if sequence >= m.next {
+		m.next = sequence + 1
+	}
+}
+
+// Sync updates the tracked sequence with the one committed on chain,
+// pending txs with a lower sequence are committed and no longer tracked
+func (m *SequenceManager) Sync(chainSequence uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for seq := range m.pending {
+		if seq < chainSequence {
+			delete(m.pending, seq)
+		}
+	}
+
+	if len(m.pending) == 0 || chainSequence > m.next {
+		m.next = chainSequence
+	}
+}
+
+// Reset sets the next sequence to the one expected by the node, dropping every pending tx
+// signed with a sequence that is not going to be used anymore
+func (m *SequenceManager) Reset(expected uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for seq := range m.pending {
+		if seq >= expected {
+			delete(m.pending, seq)
+		}
+	}
+	m.next = expected
+}
+
+// Pending returns the hashes of the txs accepted to the mempool but not committed yet
+func (m *SequenceManager) Pending() map[uint64]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pending := make(map[uint64]string, len(m.pending))
+	for seq, hash := range m.pending {
+		pending[seq] = hash
+	}
+	return pending
+}
+
+// ParseSequenceMismatch returns the sequence expected by the node
+// if the tx was rejected due to account sequence mismatch
+func ParseSequenceMismatch(resp *cosmostypes.TxResponse) (expected uint64, mismatch bool) {
+	if resp == nil || resp.Code == 0 {
+		return 0, false
+	}
+
+	if resp.Codespace != sdkerrors.ErrWrongSequence.Codespace() || resp.Code != sdkerrors.ErrWrongSequence.ABCICode() {
+		return 0, false
+	}
+
+	matches := sequenceMismatchRegex.FindStringSubmatch(resp.RawLog)
+	if len(matches) != 3 {
+		return 0, true
+	}
+
+	expected, err := strconv.ParseUint(matches[1], 10, 64)
+	if err != nil {
+		return 0, true
+	}
+	return expected, true
+}
@@ -0,0 +1,300 @@
+package cosmosClient
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"testing"
+
+	"cosmossdk.io/math"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	cosmostypes "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	"github.com/cosmos/cosmos-sdk/types/tx"
+	authsigning "github.com/cosmos/cosmos-sdk/x/auth/signing"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	"google.golang.org/grpc"
+)
+
+func TestSequenceManager(t *testing.T) {
+	tests := []struct {
+		name        string
+		steps       func(m *SequenceManager)
+		wantNext    uint64
+		wantPending map[uint64]string
+	}{
+		{
+			name:        "accepted txs advance the sequence",
+			steps:       func(m *SequenceManager) { m.Accepted(5, "A"); m.Accepted(6, "B") },
+			wantNext:    7,
+			wantPending: map[uint64]string{5: "A", 6: "B"},
+		},
+		{
+			name:        "accepted lower sequence does not move the sequence back",
+			steps:       func(m *SequenceManager) { m.Accepted(6, "B"); m.Accepted(5, "A") },
+			wantNext:    7,
+			wantPending: map[uint64]string{5: "A", 6: "B"},
+		},
+		{
+			name:        "sync drops committed txs & keeps pending ones",
+			steps:       func(m *SequenceManager) { m.Accepted(5, "A"); m.Accepted(6, "B"); m.Sync(6) },
+			wantNext:    7,
+			wantPending: map[uint64]string{6: "B"},
+		},
+		{
+			name:        "sync with every tx committed",
+			steps:       func(m *SequenceManager) { m.Accepted(5, "A"); m.Sync(6) },
+			wantNext:    6,
+			wantPending: map[uint64]string{},
+		},
+		{
+			name:        "sync with a sequence ahead of the tracked one",
+			steps:       func(m *SequenceManager) { m.Accepted(5, "A"); m.Sync(9) },
+			wantNext:    9,
+			wantPending: map[uint64]string{},
+		},
+		{
+			name:        "sync without pending txs moves the sequence back",
+			steps:       func(m *SequenceManager) { m.Sync(3) },
+			wantNext:    3,
+			wantPending: map[uint64]string{},
+		},
+		{
+			name:        "reset drops txs signed from the expected sequence",
+			steps:       func(m *SequenceManager) { m.Accepted(5, "A"); m.Accepted(6, "B"); m.Accepted(7, "C"); m.Reset(6) },
+			wantNext:    6,
+			wantPending: map[uint64]string{5: "A"},
+		},
+		{
+			name:        "accepted after reset",
+			steps:       func(m *SequenceManager) { m.Accepted(5, "A"); m.Accepted(6, "B"); m.Reset(6); m.Accepted(6, "D") },
+			wantNext:    7,
+			wantPending: map[uint64]string{5: "A", 6: "D"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewSequenceManager(5)
+			tt.steps(m)
+
+			if next := m.Next(); next != tt.wantNext {
+				t.Fatalf("Next() = %d, want %d", next, tt.wantNext)
+			}
+			if pending := m.Pending(); !maps.Equal(pending, tt.wantPending) {
+				t.Fatalf("Pending() = %v, want %v", pending, tt.wantPending)
+			}
+		})
+	}
+}
+
+func TestParseSequenceMismatch(t *testing.T) {
+	wrongSequence := func(rawLog string) *cosmostypes.TxResponse {
+		return &cosmostypes.TxResponse{
+			Codespace: sdkerrors.ErrWrongSequence.Codespace(),
+			Code:      sdkerrors.ErrWrongSequence.ABCICode(),
+			RawLog:    rawLog,
+		}
+	}
+
+	tests := []struct {
+		name         string
+		resp         *cosmostypes.TxResponse
+		wantExpected uint64
+		wantMismatch bool
+	}{
+		{name: "nil response", resp: nil},
+		{name: "accepted tx", resp: &cosmostypes.TxResponse{Code: 0}},
+		{
+			name: "other error",
+			resp: &cosmostypes.TxResponse{
+				Codespace: sdkerrors.ErrInsufficientFee.Codespace(),
+				Code:      sdkerrors.ErrInsufficientFee.ABCICode(),
+				RawLog:    "account sequence mismatch, expected 12, got 10: insufficient fee",
+			},
+		},
+		{
+			name:         "wrong sequence",
+			resp:         wrongSequence("account sequence mismatch, expected 12, got 10: incorrect account sequence"),
+			wantExpected: 12,
+			wantMismatch: true,
+		},
+		{
+			name:         "wrong sequence with unknown raw log",
+			resp:         wrongSequence("incorrect account sequence"),
+			wantExpected: 0,
+			wantMismatch: true,
+		},
+		{
+			name:         "wrong sequence with expected sequence overflowing",
+			resp:         wrongSequence("account sequence mismatch, expected 18446744073709551616, got 10: incorrect account sequence"),
+			wantExpected: 0,
+			wantMismatch: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expected, mismatch := ParseSequenceMismatch(tt.resp)
+			if expected != tt.wantExpected || mismatch != tt.wantMismatch {
+				t.Fatalf(
+					"ParseSequenceMismatch() = (%d, %v), want (%d, %v)",
+					expected, mismatch, tt.wantExpected, tt.wantMismatch,
+				)
+			}
+		})
+	}
+}
+
+// fakeTxService answers broadcasts with the given responses in order, recording the sequence of every broadcast tx
+type fakeTxService struct {
+	tx.ServiceClient
+	t         *testing.T
+	decoder   cosmostypes.TxDecoder
+	responses []*cosmostypes.TxResponse
+	sequences []uint64
+}
+
+func (s *fakeTxService) BroadcastTx(_ context.Context, req *tx.BroadcastTxRequest, _ ...grpc.CallOption) (*tx.BroadcastTxResponse, error) {
+	decoded, err := s.decoder(req.TxBytes)
+	if err != nil {
+		s.t.Fatalf("error decoding broadcast tx: %s", err)
+	}
+	sigs, err := decoded.(authsigning.SigVerifiableTx).GetSignaturesV2()
+	if err != nil || len(sigs) != 1 {
+		s.t.Fatalf("expected 1 signature, got %d, err: %v", len(sigs), err)
+	}
+	s.sequences = append(s.sequences, sigs[0].Sequence)
+
+	if len(s.responses) == 0 {
+		s.t.Fatalf("unexpected broadcast with sequence %d", sigs[0].Sequence)
+	}
+	resp := *s.responses[0]
+	s.responses = s.responses[1:]
+	resp.TxHash = txHash(req.TxBytes)
+	return &tx.BroadcastTxResponse{TxResponse: &resp}, nil
+}
+
+// fakeAuthClient returns the account with the given sequence
+type fakeAuthClient struct {
+	authtypes.QueryClient
+	account *codectypes.Any
+}
+
+func (a *fakeAuthClient) Account(context.Context, *authtypes.QueryAccountRequest, ...grpc.CallOption) (*authtypes.QueryAccountResponse, error) {
+	return &authtypes.QueryAccountResponse{Account: a.account}, nil
+}
+
+func TestBroadcastSequenceMismatch(t *testing.T) {
+	accepted := &cosmostypes.TxResponse{Code: 0}
+	mismatch := func(expected, got uint64) *cosmostypes.TxResponse {
+		return &cosmostypes.TxResponse{
+			Codespace: sdkerrors.ErrWrongSequence.Codespace(),
+			Code:      sdkerrors.ErrWrongSequence.ABCICode(),
+			RawLog:    fmt.Sprintf("account sequence mismatch, expected %d, got %d: incorrect account sequence", expected, got),
+		}
+	}
+	unknownMismatch := &cosmostypes.TxResponse{
+		Codespace: sdkerrors.ErrWrongSequence.Codespace(),
+		Code:      sdkerrors.ErrWrongSequence.ABCICode(),
+		RawLog:    "incorrect account sequence",
+	}
+
+	// Client starts at sequence 7, the chain account is at sequence 8
+	tests := []struct {
+		name          string
+		broadcasts    int
+		responses     []*cosmostypes.TxResponse
+		wantSequences []uint64
+		wantErr       bool
+		wantNext      uint64
+		wantPending   []uint64
+	}{
+		{
+			name:          "accepted right away",
+			broadcasts:    1,
+			responses:     []*cosmostypes.TxResponse{accepted},
+			wantSequences: []uint64{7},
+			wantNext:      8,
+			wantPending:   []uint64{7},
+		},
+		{
+			name:          "re-signed with the expected sequence",
+			broadcasts:    1,
+			responses:     []*cosmostypes.TxResponse{mismatch(9, 7), accepted},
+			wantSequences: []uint64{7, 9},
+			wantNext:      10,
+			wantPending:   []uint64{9},
+		},
+		{
+			name:          "re-signed with the chain sequence when the expected one is unknown",
+			broadcasts:    1,
+			responses:     []*cosmostypes.TxResponse{unknownMismatch, accepted},
+			wantSequences: []uint64{7, 8},
+			wantNext:      9,
+			wantPending:   []uint64{8},
+		},
+		{
+			name:          "previous tx evicted from the mempool",
+			broadcasts:    2,
+			responses:     []*cosmostypes.TxResponse{accepted, mismatch(7, 8), accepted},
+			wantSequences: []uint64{7, 8, 7},
+			wantNext:      8,
+			wantPending:   []uint64{7},
+		},
+		{
+			name:       "gives up after the max sequence retries",
+			broadcasts: 1,
+			responses: []*cosmostypes.TxResponse{
+				mismatch(9, 7), mismatch(10, 9), mismatch(11, 10), mismatch(12, 11),
+			},
+			wantSequences: []uint64{7, 9, 10, 11},
+			wantErr:       true,
+			wantNext:      11,
+			wantPending:   []uint64{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestOfflineClient(t)
+			c.SetFeeOptions(FeeOptions{GasPrice: cosmostypes.NewDecCoinFromDec("ufair", math.LegacyMustNewDecFromStr("0.025"))})
+
+			account, err := codectypes.NewAnyWithValue(authtypes.NewBaseAccount(c.GetAccAddress(), nil, 3, 8))
+			if err != nil {
+				t.Fatalf("error packing account: %s", err)
+			}
+			c.authClient = &fakeAuthClient{account: account}
+			txService := &fakeTxService{t: t, decoder: c.encodingConfig.TxConfig.TxDecoder(), responses: tt.responses}
+			c.txClient = txService
+
+			msg := &banktypes.MsgSend{
+				FromAddress: c.GetAddress(),
+				ToAddress:   c.GetAddress(),
+				Amount:      cosmostypes.NewCoins(cosmostypes.NewInt64Coin("ufair", 1)),
+			}
+			for i := 0; i < tt.broadcasts; i++ {
+				_, err = c.broadcast(context.Background(), msg, false, c.feeOptions)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("broadcast() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(txService.sequences, tt.wantSequences) {
+				t.Fatalf("broadcast sequences = %v, want %v", txService.sequences, tt.wantSequences)
+			}
+			if next := c.sequence.Next(); next != tt.wantNext {
+				t.Fatalf("next sequence = %d, want %d", next, tt.wantNext)
+			}
+			pending := make([]uint64, 0)
+			for sequence := range c.GetPendingTxs() {
+				pending = append(pending, sequence)
+			}
+			slices.Sort(pending)
+			if !slices.Equal(pending, tt.wantPending) {
+				t.Fatalf("pending sequences = %v, want %v", pending, tt.wantPending)
+			}
+		})
+	}
+}
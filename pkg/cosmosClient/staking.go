@@ -0,0 +1,79 @@
+package cosmosClient
+
+import (
+	"context"
+
+	queryv1beta1 "cosmossdk.io/api/cosmos/base/query/v1beta1"
+	stakingv1beta1 "cosmossdk.io/api/cosmos/staking/v1beta1"
+	"cosmossdk.io/math"
+	cosmostypes "github.com/cosmos/cosmos-sdk/types"
+	dcrdSecp256k1 "github.com/decred/dcrd/dcrec/secp256k1"
+	"github.com/pkg/errors"
+)
+
+const bondedStatus = "BOND_STATUS_BONDED"
+
+// ValidatorStake is the bonded stake of a validator, keyed by its account address
+type ValidatorStake struct {
+	Moniker string
+	Tokens  math.Int
+}
+
+// GetBondedStakes returns the stake of every bonded validator by account address, and the total bonded stake
+func (c *CosmosClient) GetBondedStakes(ctx context.Context) (map[string]ValidatorStake, math.Int, error) {
+	stakes := make(map[string]ValidatorStake)
+	total := math.ZeroInt()
+
+	var nextKey []byte
+	for {
+		resp, err := c.stakingQueryClient.Validators(ctx, &stakingv1beta1.QueryValidatorsRequest{
+			Status:     bondedStatus,
+			Pagination: &queryv1beta1.PageRequest{Key: nextKey},
+		})
+		if err != nil {
+			return nil, total, errors.Wrap(err, "error querying bonded validators")
+		}
+
+		for _, v := range resp.Validators {
+			valAddr, err := cosmostypes.ValAddressFromBech32(v.OperatorAddress)
+			if err != nil {
+				return nil, total, errors.Wrapf(err, "error decoding validator address %s", v.OperatorAddress)
+			}
+			tokens, ok := math.NewIntFromString(v.Tokens)
+			if !ok {
+				return nil, total, errors.Errorf("invalid tokens of validator %s: %s", v.OperatorAddress, v.Tokens)
+			}
+
+			stake := ValidatorStake{Tokens: tokens}
+			if v.Description != nil {
+				stake.Moniker = v.Description.Moniker
+			}
+			stakes[cosmostypes.AccAddress(valAddr).String()] = stake
+			total = total.Add(tokens)
+		}
+
+		if resp.Pagination == nil || len(resp.Pagination.NextKey) == 0 {
+			return stakes, total, nil
+		}
+		nextKey = resp.Pagination.NextKey
+	}
+}
+
+// GetAccountPubKey returns the pub key shares are encrypted to for the account, nil if it has none yet
+func (c *CosmosClient) GetAccountPubKey(ctx context.Context, address string) (*dcrdSecp256k1.PublicKey, error) {
+	account, err := c.GetAccount(ctx, address)
+	if err != nil {
+		return nil, errors.Wrap(err, "error when querying account info")
+	}
+
+	secp256k1PubKey, err := accountSecp256k1PubKey(account)
+	if err != nil || secp256k1PubKey == nil {
+		return nil, err
+	}
+
+	pubKey, err := dcrdSecp256k1.ParsePubKey(secp256k1PubKey.Key)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing pub key to dcrd pub key")
+	}
+	return pubKey, nil
+}